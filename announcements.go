@@ -55,22 +55,34 @@ func (a *Announcement) parse(line string) error {
 	return nil
 }
 
-func sendText(cmdQueue chan<- command, text string) {
+// sendText splits the text into say commands that fit into a line of the server's chat.
+// It stops as soon as the context is done.
+func sendText(ctx context.Context, cmdQueue chan<- command, text string) {
 	words := strings.Split(text, " ")
 
 	if text == "" {
 		return
 	}
 
+	say := func(words []string) bool {
+		select {
+		case cmdQueue <- command{
+			Author:  "announcement",
+			Command: fmt.Sprintf("say %s", strings.TrimSpace(strings.Join(words, " "))),
+		}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
 	buffer := make([]string, 0, len(words))
 	bufferStrLen := 0
 	for _, word := range words {
 
 		if bufferStrLen+len(buffer)*1+len(word) > serverMessageWidth {
-
-			cmdQueue <- command{
-				Author:  "announcement",
-				Command: fmt.Sprintf("say %s", strings.TrimSpace(strings.Join(buffer, " "))),
+			if !say(buffer) {
+				return
 			}
 			buffer = buffer[:0]
 			bufferStrLen = 0
@@ -81,10 +93,7 @@ func sendText(cmdQueue chan<- command, text string) {
 	}
 
 	if len(buffer) > 0 {
-		cmdQueue <- command{
-			Author:  "announcement",
-			Command: fmt.Sprintf("say %s", strings.TrimSpace(strings.Join(buffer, " "))),
-		}
+		say(buffer)
 	}
 }
 
@@ -143,7 +152,7 @@ func (as *AnnouncementServer) start() {
 			}
 
 			ticker = time.NewTicker(ann.Delay)
-			sendText(as.commandQueue, ann.Message)
+			sendText(as.ctx, as.commandQueue, ann.Message)
 		}
 	}
 }
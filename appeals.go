@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// a denied user has to wait this long before appealing again
+	appealCooldown = 24 * time.Hour
+)
+
+// possible appeal states
+const (
+	AppealOpen     = "open"
+	AppealAccepted = "accepted"
+	AppealDenied   = "denied"
+)
+
+var (
+	// ErrAppealNotFound is returned if an appeal does not exist.
+	ErrAppealNotFound = errors.New("appeal not found")
+
+	// ErrAppealClosed is returned if an appeal was already accepted or denied.
+	ErrAppealClosed = errors.New("appeal was already decided")
+
+	// ErrAppealPending is returned if the user already has an open appeal.
+	ErrAppealPending = errors.New("you already have an open appeal, please wait for the moderators to decide")
+
+	// ErrAppealCooldown is returned if the user's last appeal was denied recently.
+	ErrAppealCooldown = errors.New("your last appeal was denied, please try again later")
+)
+
+// AppealBan is a ban that is appealed.
+type AppealBan struct {
+	Server Address
+	Ban    Ban
+}
+
+// Appeal is the request of a banned player to be unbanned.
+type Appeal struct {
+	ID        int
+	UserID    string
+	User      string
+	Nickname  string
+	Message   string
+	Bans      []AppealBan
+	Status    string
+	DecidedBy string
+	CreatedAt time.Time
+	DecidedAt time.Time
+
+	// forwarded message in the moderation channel
+	ChannelID string
+	MessageID string
+}
+
+// String formats the appeal for the moderators, IPs are not shown.
+func (a *Appeal) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("**[appeal #%d]**: %s appeals the ban(s) of '%s'\n", a.ID, a.User, Escape(a.Nickname)))
+	sb.WriteString("```\n")
+	for _, b := range a.Bans {
+		banTimeLeft := "permanent"
+		if !b.Ban.Permanent() {
+			banTimeLeft = time.Until(b.Ban.ExpiresAt).Round(time.Second).String()
+		}
+		sb.WriteString(fmt.Sprintf("%s %9s '%s' (%s)\n", b.Server, banTimeLeft, b.Ban.Player.Name, b.Ban.Reason))
+	}
+	sb.WriteString("```\n")
+
+	switch a.Status {
+	case AppealAccepted:
+		sb.WriteString(fmt.Sprintf("**Accepted** by %s\n", a.DecidedBy))
+	case AppealDenied:
+		sb.WriteString(fmt.Sprintf("**Denied** by %s\n", a.DecidedBy))
+	}
+
+	// block quote until the end of the message
+	if a.Message != "" {
+		sb.WriteString(fmt.Sprintf(">>> %s", a.Message))
+	}
+	return sb.String()
+}
+
+// AppealMap keeps track of all appeals.
+type AppealMap struct {
+	mu      sync.Mutex
+	nextID  int
+	appeals map[int]*Appeal
+}
+
+// Open creates a new appeal, each Discord user can have a single open appeal at a time.
+func (m *AppealMap) Open(appeal Appeal) (Appeal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.appeals == nil {
+		m.appeals = make(map[int]*Appeal)
+	}
+
+	for _, a := range m.appeals {
+		if a.UserID != appeal.UserID {
+			continue
+		}
+
+		if a.Status == AppealOpen {
+			return Appeal{}, ErrAppealPending
+		}
+
+		if a.Status == AppealDenied && time.Since(a.DecidedAt) < appealCooldown {
+			return Appeal{}, ErrAppealCooldown
+		}
+	}
+
+	m.nextID++
+	appeal.ID = m.nextID
+	appeal.Status = AppealOpen
+	appeal.CreatedAt = time.Now()
+
+	m.appeals[appeal.ID] = &appeal
+	return appeal, nil
+}
+
+// SetMessage associates the forwarded message with the appeal.
+func (m *AppealMap) SetMessage(id int, channelID, messageID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a, ok := m.appeals[id]; ok {
+		a.ChannelID = channelID
+		a.MessageID = messageID
+	}
+}
+
+// Remove removes an appeal, e.g. when it could not be forwarded.
+func (m *AppealMap) Remove(id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.appeals, id)
+}
+
+// Decide accepts or denies an open appeal.
+func (m *AppealMap) Decide(id int, status, moderator string) (Appeal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.appeals[id]
+	if !ok {
+		return Appeal{}, ErrAppealNotFound
+	}
+
+	if a.Status != AppealOpen {
+		return *a, ErrAppealClosed
+	}
+
+	a.Status = status
+	a.DecidedBy = moderator
+	a.DecidedAt = time.Now()
+	return *a, nil
+}
+
+// nicknameMatches returns true if the name of a ban is the nickname or, in case of joined
+// nicknames of the nickname tracking, contains the nickname.
+func nicknameMatches(banName, nickname string) bool {
+	for _, name := range strings.Split(banName, ", ") {
+		if strings.EqualFold(name, nickname) {
+			return true
+		}
+	}
+	return false
+}
+
+// findBansByNickname looks for the active bans of a nickname on all moderated servers.
+// Bans of IPs that the nickname was seen with in the ban history and the nickname tracking are found as well.
+func (b *Bot) findBansByNickname(nickname string) []AppealBan {
+	ips := make(map[string]bool)
+
+	events, _ := b.BanHistory.ByName(nickname)
+	for _, e := range events {
+		ips[e.Player.IP] = true
+	}
+
+	knownIPs, _ := b.NicknameTracker.IPs(nickname)
+	for _, ip := range knownIPs {
+		ips[ip] = true
+	}
+
+	result := make([]AppealBan, 0, 1)
+	for _, addr := range b.ChannelAddress.GetAddresses() {
+		for _, ban := range b.ServerStates[addr].BanServer.Bans() {
+			if ips[ban.Player.IP] || nicknameMatches(ban.Player.Name, nickname) {
+				result = append(result, AppealBan{Server: addr, Ban: ban})
+			}
+		}
+	}
+	return result
+}
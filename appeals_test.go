@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jxsl13/TeeworldsEconDiscordModerationBot/discordtest"
+)
+
+func TestAppealMap(t *testing.T) {
+	m := AppealMap{}
+
+	appeal, err := m.Open(Appeal{UserID: "1", Nickname: "nameless tee"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if appeal.ID != 1 || appeal.Status != AppealOpen {
+		t.Errorf("unexpected appeal: %+v", appeal)
+	}
+
+	if _, err := m.Open(Appeal{UserID: "1"}); err != ErrAppealPending {
+		t.Errorf("expected %v, got %v", ErrAppealPending, err)
+	}
+
+	if _, err := m.Open(Appeal{UserID: "2"}); err != nil {
+		t.Errorf("unexpected error for a different user: %s", err)
+	}
+
+	decided, err := m.Decide(appeal.ID, AppealDenied, "moderator")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if decided.Status != AppealDenied || decided.DecidedBy != "moderator" {
+		t.Errorf("unexpected appeal: %+v", decided)
+	}
+
+	if _, err := m.Decide(appeal.ID, AppealAccepted, "moderator"); err != ErrAppealClosed {
+		t.Errorf("expected %v, got %v", ErrAppealClosed, err)
+	}
+
+	if _, err := m.Decide(42, AppealAccepted, "moderator"); err != ErrAppealNotFound {
+		t.Errorf("expected %v, got %v", ErrAppealNotFound, err)
+	}
+
+	if _, err := m.Open(Appeal{UserID: "1"}); err != ErrAppealCooldown {
+		t.Errorf("expected %v, got %v", ErrAppealCooldown, err)
+	}
+}
+
+func TestNicknameMatches(t *testing.T) {
+	tests := []struct {
+		banName  string
+		nickname string
+		want     bool
+	}{
+		{"nameless tee", "Nameless Tee", true},
+		{"brainless tee, nameless tee", "nameless tee", true},
+		{"nameless tee", "nameless", false},
+		{"", "nameless tee", false},
+	}
+
+	for _, tt := range tests {
+		if got := nicknameMatches(tt.banName, tt.nickname); got != tt.want {
+			t.Errorf("nicknameMatches(%q, %q) = %v, want %v", tt.banName, tt.nickname, got, tt.want)
+		}
+	}
+}
+
+func TestDirectMessageHandler(t *testing.T) {
+	b := newTestBot()
+	b.ServerStates[testAddress].BanServer.Ban(Player{ID: -1, Name: "griefer", IP: "192.168.178.26"}, time.Hour, "spam")
+	session := discordtest.NewSession()
+
+	b.DirectMessageHandler(session, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ChannelID: "dm",
+			Author:    testUser,
+			Content:   "?appeal griefer\nit was my brother",
+		},
+	})
+
+	msg, err := session.WaitForMessage(testChannelID, "it was my brother", time.Second)
+	if err != nil {
+		t.Fatalf("appeal was not forwarded: %v", err)
+	}
+	if len(msg.Components) == 0 {
+		t.Errorf("appeal has no buttons")
+	}
+	if _, err := session.WaitForMessage("dm", "has been forwarded", time.Second); err != nil {
+		t.Errorf("user was not notified: %v, messages: %#v", err, session.Messages("dm"))
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// supported ban file formats
+const (
+	banFormatJSON = "json"
+	banFormatCSV  = "csv"
+	banFormatCfg  = "cfg"
+
+	// imported ban files must not be bigger than this
+	maxBanFileSize = 1024 * 1024
+)
+
+var (
+	banFileClient = &http.Client{Timeout: 30 * time.Second}
+
+	// ErrUnknownBanFormat is returned if a ban file is neither json, csv nor a Teeworlds config file.
+	ErrUnknownBanFormat = errors.New("unknown ban file format, expected json, csv or cfg")
+
+	banCSVHeader = []string{"ip", "name", "minutes", "reason"}
+)
+
+// BanRecord is a single entry of an exported ban list.
+// The IP is either a single IP or an IP range, zero minutes ban permanently.
+type BanRecord struct {
+	IP      string `json:"ip"`
+	Name    string `json:"name"`
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason"`
+}
+
+// remainingMinutes rounds the remaining ban time up, the same way the Teeworlds server does.
+func remainingMinutes(expiresAt time.Time) int {
+	if expiresAt.IsZero() {
+		return 0
+	}
+
+	minutes := int(math.Ceil(time.Until(expiresAt).Minutes()))
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+// BanRecordsFromBans converts the bans of a server into exportable records.
+func BanRecordsFromBans(bans []Ban) []BanRecord {
+	records := make([]BanRecord, 0, len(bans))
+	for _, ban := range bans {
+		records = append(records, BanRecord{
+			IP:      ban.Player.IP,
+			Name:    ban.Player.Name,
+			Minutes: remainingMinutes(ban.ExpiresAt),
+			Reason:  ban.Reason,
+		})
+	}
+	return records
+}
+
+// BanRecordsFromGlobalBans converts the global bans into exportable records.
+func BanRecordsFromGlobalBans(bans []GlobalBan) []BanRecord {
+	records := make([]BanRecord, 0, len(bans))
+	for _, ban := range bans {
+		records = append(records, BanRecord{
+			IP:      ban.IP,
+			Name:    ban.Name,
+			Minutes: remainingMinutes(ban.ExpiresAt),
+			Reason:  ban.Reason,
+		})
+	}
+	return records
+}
+
+// banFormatByFilename returns the ban file format based on the file extension.
+func banFormatByFilename(filename string) (string, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		return banFormatJSON, nil
+	case ".csv":
+		return banFormatCSV, nil
+	case ".cfg", ".txt":
+		return banFormatCfg, nil
+	default:
+		return "", ErrUnknownBanFormat
+	}
+}
+
+// EncodeBans encodes the records in the passed format.
+// The cfg format contains ban and ban_range commands like the ones created by bans_save.
+func EncodeBans(format string, records []BanRecord) ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	switch format {
+	case banFormatJSON:
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	case banFormatCSV:
+		w := csv.NewWriter(&buf)
+		w.Write(banCSVHeader)
+		for _, r := range records {
+			w.Write([]string{r.IP, r.Name, strconv.Itoa(r.Minutes), r.Reason})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+	case banFormatCfg:
+		for _, r := range records {
+			buf.WriteString(banCommand(r.IP, r.Minutes, r.Reason))
+			buf.WriteString("\n")
+		}
+	default:
+		return nil, ErrUnknownBanFormat
+	}
+	return buf.Bytes(), nil
+}
+
+// validate normalizes the IP or IP range of the record.
+func (r *BanRecord) validate() error {
+	ipRange, err := ParseIPRange(r.IP)
+	if err != nil {
+		return fmt.Errorf("invalid IP '%s'", r.IP)
+	}
+	r.IP = ipRange.String()
+
+	if r.Minutes < 0 {
+		return fmt.Errorf("invalid minutes '%d'", r.Minutes)
+	}
+	return nil
+}
+
+// DecodeBans decodes and validates the records of a ban file.
+// Invalid entries are skipped and returned as a list of error messages.
+func DecodeBans(format string, r io.Reader) (records []BanRecord, invalid []string, err error) {
+	records = make([]BanRecord, 0, 16)
+	invalid = make([]string, 0, 1)
+
+	addRecord := func(entry string, record BanRecord, err error) {
+		if err == nil {
+			err = record.validate()
+		}
+
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", entry, err.Error()))
+			return
+		}
+		records = append(records, record)
+	}
+
+	switch format {
+	case banFormatJSON:
+		var decoded []BanRecord
+		err = json.NewDecoder(r).Decode(&decoded)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for idx, record := range decoded {
+			addRecord(fmt.Sprintf("entry %d", idx), record, nil)
+		}
+	case banFormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+
+		rows, err := cr.ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for idx, row := range rows {
+			if idx == 0 && len(row) > 0 && strings.EqualFold(row[0], banCSVHeader[0]) {
+				continue
+			}
+
+			entry := fmt.Sprintf("line %d", idx+1)
+			if len(row) != len(banCSVHeader) {
+				addRecord(entry, BanRecord{}, fmt.Errorf("expected %d columns, got %d", len(banCSVHeader), len(row)))
+				continue
+			}
+
+			minutes, err := strconv.Atoi(strings.TrimSpace(row[2]))
+			if err != nil {
+				err = fmt.Errorf("invalid minutes '%s'", row[2])
+			}
+			addRecord(entry, BanRecord{IP: strings.TrimSpace(row[0]), Name: row[1], Minutes: minutes, Reason: row[3]}, err)
+		}
+	case banFormatCfg:
+		scanner := bufio.NewScanner(r)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			record, err := parseBanCommand(line)
+			addRecord(fmt.Sprintf("line %d", lineNumber), record, err)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, ErrUnknownBanFormat
+	}
+	return records, invalid, nil
+}
+
+// parseBanCommand parses the ban and ban_range commands of a Teeworlds config file.
+func parseBanCommand(line string) (BanRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return BanRecord{}, errors.New("empty line")
+	}
+
+	var record BanRecord
+	switch fields[0] {
+	case "ban":
+		if len(fields) < 3 {
+			return BanRecord{}, errors.New("expected: ban <ip> <minutes> [reason]")
+		}
+		record.IP = fields[1]
+		fields = fields[2:]
+	case "ban_range":
+		if len(fields) < 4 {
+			return BanRecord{}, errors.New("expected: ban_range <first ip> <last ip> <minutes> [reason]")
+		}
+		record.IP = fields[1] + "-" + fields[2]
+		fields = fields[3:]
+	default:
+		return BanRecord{}, fmt.Errorf("unknown command '%s'", fields[0])
+	}
+
+	minutes, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return BanRecord{}, fmt.Errorf("invalid minutes '%s'", fields[0])
+	}
+	record.Minutes = minutes
+	record.Reason = strings.Join(fields[1:], " ")
+	return record, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeBans(t *testing.T) {
+	records := []BanRecord{
+		{IP: "10.0.0.1", Name: "spammer", Minutes: 30, Reason: "spam, flame"},
+		{IP: "10.0.0.0 - 10.0.0.255", Name: "(unknown)", Minutes: 60, Reason: "bots"},
+		{IP: "10.0.1.1", Name: "cheater", Minutes: 0, Reason: "cheats"},
+	}
+
+	for _, format := range []string{banFormatJSON, banFormatCSV, banFormatCfg} {
+		data, err := EncodeBans(format, records)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, invalid, err := DecodeBans(format, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(invalid) != 0 {
+			t.Fatalf("%s: unexpected invalid entries: %v", format, invalid)
+		}
+
+		// the cfg format does not contain any names
+		if format == banFormatCfg {
+			for idx := range decoded {
+				decoded[idx].Name = records[idx].Name
+			}
+		}
+
+		if !reflect.DeepEqual(decoded, records) {
+			t.Fatalf("%s: expected %v, got %v", format, records, decoded)
+		}
+	}
+}
+
+func TestDecodeBans_Invalid(t *testing.T) {
+	cfg := `# exported ban list
+ban 10.0.0.1 30 spam
+ban nameless 30 spam
+ban_range 10.0.0.20 10.0.0.1 30 bots
+ban 10.0.0.2 soon
+kick 3
+`
+
+	records, invalid, err := DecodeBans(banFormatCfg, strings.NewReader(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].IP != "10.0.0.1" {
+		t.Fatalf("Expected a single valid ban, got %v", records)
+	}
+
+	if len(invalid) != 4 || !strings.HasPrefix(invalid[0], "line 3:") {
+		t.Fatalf("Expected 4 invalid lines, got %v", invalid)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// bans per page of ?bans, small enough to fit into a single Discord message.
+	bansPageSize = 10
+
+	banSortRemaining = "remaining"
+	banSortAge       = "age"
+)
+
+var (
+	// ErrInvalidBanFilter is returned if ?bans is called with invalid arguments.
+	ErrInvalidBanFilter = errors.New("invalid filter, expected: ?bans [name=<name>] [reason=<text>] [ip=<prefix>] [longer=<1h>] [shorter=<1d>] [sort=remaining|age]")
+)
+
+// BanFilter restricts and sorts the ban list of a server.
+type BanFilter struct {
+	Name    string
+	Reason  string
+	IP      string
+	Longer  time.Duration
+	Shorter time.Duration
+	Sort    string
+}
+
+// IndexedBan is a ban together with its index in the server's ban list.
+type IndexedBan struct {
+	Index int
+	Ban
+}
+
+// ParseBanFilter parses filters like: name=nameless tee reason=spam ip=10.0. longer=1h sort=age
+// Values may contain spaces, every word without a key belongs to the previous value.
+func ParseBanFilter(args string) (BanFilter, error) {
+	filter := BanFilter{Sort: banSortRemaining}
+
+	values := make(map[string]string)
+	keys := make([]string, 0, 4)
+	for _, field := range strings.Fields(args) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) == 2 {
+			key := strings.ToLower(kv[0])
+			keys = append(keys, key)
+			values[key] = kv[1]
+		} else if len(keys) > 0 {
+			last := keys[len(keys)-1]
+			values[last] += " " + field
+		} else {
+			return BanFilter{}, ErrInvalidBanFilter
+		}
+	}
+
+	for key, value := range values {
+		var err error
+
+		switch key {
+		case "name":
+			filter.Name = strings.ToLower(value)
+		case "reason":
+			filter.Reason = strings.ToLower(value)
+		case "ip":
+			filter.IP = value
+		case "longer":
+			filter.Longer, err = parseDuration(value)
+		case "shorter":
+			filter.Shorter, err = parseDuration(value)
+		case "sort":
+			filter.Sort = strings.ToLower(value)
+			if filter.Sort != banSortRemaining && filter.Sort != banSortAge {
+				err = ErrInvalidBanFilter
+			}
+		default:
+			err = ErrInvalidBanFilter
+		}
+
+		if err != nil {
+			return BanFilter{}, ErrInvalidBanFilter
+		}
+	}
+	return filter, nil
+}
+
+// Match returns true if the ban passes all filters.
+func (f *BanFilter) Match(ban Ban) bool {
+	if f.Name != "" && !strings.Contains(strings.ToLower(ban.Player.Name), f.Name) {
+		return false
+	}
+
+	if f.Reason != "" && !strings.Contains(strings.ToLower(ban.Reason), f.Reason) {
+		return false
+	}
+
+	if f.IP != "" && !strings.HasPrefix(ban.Player.IP, f.IP) {
+		return false
+	}
+
+	if f.Longer > 0 && !ban.Permanent() && time.Until(ban.ExpiresAt) <= f.Longer {
+		return false
+	}
+
+	if f.Shorter > 0 && (ban.Permanent() || time.Until(ban.ExpiresAt) >= f.Shorter) {
+		return false
+	}
+	return true
+}
+
+// Apply filters and sorts the ban list, the indices of the server's ban list are kept.
+func (f *BanFilter) Apply(bans []Ban) []IndexedBan {
+	result := make([]IndexedBan, 0, len(bans))
+	for idx, ban := range bans {
+		if f.Match(ban) {
+			result = append(result, IndexedBan{Index: idx, Ban: ban})
+		}
+	}
+
+	// the ban list is already sorted by the remaining ban time
+	if f.Sort == banSortAge {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].BannedAt.After(result[j].BannedAt)
+		})
+	}
+	return result
+}
+
+// bansPage formats a single page of the filtered ban list.
+// The page is clamped to the existing pages.
+func bansPage(bans []Ban, filter BanFilter, page int) (content string, currentPage, pages int) {
+	filtered := filter.Apply(bans)
+
+	pages = (len(filtered) + bansPageSize - 1) / bansPageSize
+	if pages == 0 {
+		return fmt.Sprintf("[banlist]: 0 of %d ban(s)", len(bans)), 0, 0
+	}
+
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	end := (page + 1) * bansPageSize
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("[banlist]: %d of %d ban(s), page %d/%d\n```", len(filtered), len(bans), page+1, pages))
+	for _, ban := range filtered[page*bansPageSize : end] {
+		sb.WriteString(ban.Format(ban.Index))
+	}
+	sb.WriteString("```")
+
+	return sb.String(), page, pages
+}
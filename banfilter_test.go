@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBanFilter(t *testing.T) {
+	filter, err := ParseBanFilter("name=nameless tee reason=Spam ip=10.0. longer=1h shorter=1d sort=age")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BanFilter{
+		Name:    "nameless tee",
+		Reason:  "spam",
+		IP:      "10.0.",
+		Longer:  time.Hour,
+		Shorter: 24 * time.Hour,
+		Sort:    banSortAge,
+	}
+
+	if filter != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, filter)
+	}
+
+	for _, args := range []string{"nameless", "color=red", "longer=soon", "sort=name"} {
+		if _, err := ParseBanFilter(args); err == nil {
+			t.Errorf("%q should be invalid", args)
+		}
+	}
+}
+
+func TestBanFilter_Apply(t *testing.T) {
+	now := time.Now()
+
+	bans := []Ban{
+		{Player: Player{Name: "short", IP: "10.0.0.1"}, ExpiresAt: now.Add(5 * time.Minute), Reason: "spam", BannedAt: now.Add(-time.Hour)},
+		{Player: Player{Name: "long", IP: "10.0.0.2"}, ExpiresAt: now.Add(2 * time.Hour), Reason: "spam", BannedAt: now},
+		{Player: Player{Name: "other", IP: "10.1.0.1"}, ExpiresAt: now.Add(3 * time.Hour), Reason: "flame"},
+		{Player: Player{Name: "forever", IP: "10.0.0.3"}, Reason: "cheats spam"},
+	}
+
+	filter, _ := ParseBanFilter("reason=spam longer=1h")
+	result := filter.Apply(bans)
+	if len(result) != 2 || result[0].Index != 1 || result[1].Index != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	filter, _ = ParseBanFilter("ip=10.0. sort=age")
+	result = filter.Apply(bans)
+	if len(result) != 3 || result[0].Player.Name != "long" || result[1].Player.Name != "short" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func Test_bansPage(t *testing.T) {
+	bans := make([]Ban, 0, 25)
+	for i := 0; i < 25; i++ {
+		bans = append(bans, Ban{Player: Player{Name: "player"}, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+
+	content, page, pages := bansPage(bans, BanFilter{}, 5)
+	if page != 2 || pages != 3 {
+		t.Fatalf("Expected the last of 3 pages, got %d/%d", page+1, pages)
+	}
+
+	if !strings.Contains(content, "idx=24") || strings.Count(content, "idx=") != 5 {
+		t.Fatalf("unexpected page content: %s", content)
+	}
+}
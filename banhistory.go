@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	banEventsBucket = []byte("events")
+	banIPsBucket    = []byte("ips")
+	banNamesBucket  = []byte("names")
+)
+
+// BanEventType is the kind of a ban history entry.
+type BanEventType string
+
+// possible ban event types
+const (
+	BanEventBan    BanEventType = "ban"
+	BanEventUnban  BanEventType = "unban"
+	BanEventExpire BanEventType = "expire"
+)
+
+// BanEvent is a single entry of a player's ban history.
+type BanEvent struct {
+	Time     time.Time     `json:"time"`
+	Type     BanEventType  `json:"type"`
+	Server   Address       `json:"server"`
+	Player   Player        `json:"player"`
+	Duration time.Duration `json:"duration"`
+	Reason   string        `json:"reason"`
+
+	// Discord user that executed the command, empty if the ban was done ingame.
+	Author string `json:"author"`
+}
+
+// BanHistory is a local database that keeps track of every ban, unban and expiry.
+type BanHistory struct {
+	db *bolt.DB
+}
+
+// NewBanHistory opens or creates the ban history database file.
+func NewBanHistory(path string) (*BanHistory, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{banEventsBucket, banIPsBucket, banNamesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BanHistory{db}, nil
+}
+
+// indexKey is the key prefix followed by the big endian event sequence number.
+// Looking up all events of a prefix is a cursor seek.
+func indexKey(prefix string, seq []byte) []byte {
+	key := make([]byte, 0, len(prefix)+1+len(seq))
+	key = append(key, prefix...)
+	key = append(key, 0)
+	return append(key, seq...)
+}
+
+// Add adds an event to the history.
+func (h *BanHistory) Add(e BanEvent) error {
+	if h == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return h.db.Update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(banEventsBucket)
+
+		id, err := events.NextSequence()
+		if err != nil {
+			return err
+		}
+		seq := make([]byte, 8)
+		binary.BigEndian.PutUint64(seq, id)
+
+		err = events.Put(seq, data)
+		if err != nil {
+			return err
+		}
+
+		if e.Player.IP != "" {
+			err = tx.Bucket(banIPsBucket).Put(indexKey(e.Player.IP, seq), nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		if e.Player.Name != "" {
+			err = tx.Bucket(banNamesBucket).Put(indexKey(e.Player.Name, seq), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ByIP returns all events of an IP, oldest first.
+func (h *BanHistory) ByIP(ip string) ([]BanEvent, error) {
+	return h.lookup(banIPsBucket, ip)
+}
+
+// ByName returns all events of a nickname, oldest first.
+func (h *BanHistory) ByName(name string) ([]BanEvent, error) {
+	return h.lookup(banNamesBucket, name)
+}
+
+func (h *BanHistory) lookup(index []byte, value string) ([]BanEvent, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	result := make([]BanEvent, 0, 4)
+	err := h.db.View(func(tx *bolt.Tx) error {
+		events := tx.Bucket(banEventsBucket)
+		prefix := indexKey(value, nil)
+
+		c := tx.Bucket(index).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			var e BanEvent
+			err := json.Unmarshal(events.Get(k[len(prefix):]), &e)
+			if err != nil {
+				return err
+			}
+			result = append(result, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close closes the database file.
+func (h *BanHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.db.Close()
+}
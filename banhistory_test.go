@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banhistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := NewBanHistory(filepath.Join(dir, "bans.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	events := []BanEvent{
+		{Type: BanEventBan, Player: Player{Name: "troll", IP: "1.2.3.4"}, Duration: time.Hour, Reason: "spam", Author: "mod#1234"},
+		{Type: BanEventBan, Player: Player{Name: "troll2", IP: "1.2.3.45"}, Duration: time.Hour, Reason: "spam"},
+		{Type: BanEventExpire, Player: Player{Name: "troll", IP: "1.2.3.4"}},
+		{Type: BanEventBan, Player: Player{Name: "troll", IP: "1.2.3.5"}, Reason: "cheats"},
+	}
+
+	for _, e := range events {
+		if err := history.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byIP, err := history.ByIP("1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byIP) != 2 || byIP[0].Type != BanEventBan || byIP[1].Type != BanEventExpire {
+		t.Fatalf("unexpected history of 1.2.3.4: %+v", byIP)
+	}
+
+	if byIP[0].Author != "mod#1234" {
+		t.Fatalf("Expected 'mod#1234', got '%s'", byIP[0].Author)
+	}
+
+	byName, err := history.ByName("troll")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byName) != 3 || byName[2].Reason != "cheats" {
+		t.Fatalf("unexpected history of troll: %+v", byName)
+	}
+}
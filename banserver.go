@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -9,6 +10,11 @@ import (
 	"time"
 )
 
+const (
+	// bans that expired on time are remembered in order to ignore the server's expiry message afterwards
+	expiredBanMemory = 5 * time.Minute
+)
+
 var (
 	// ErrNoCorrespondingBanFound is returned if the passed ip could not be unbanned.
 	ErrNoCorrespondingBanFound = errors.New("could not find a corresponding match to unban")
@@ -22,17 +28,181 @@ type Ban struct {
 	Player    Player
 	ExpiresAt time.Time
 	Reason    string
+
+	// zero if the ban was already there when the bot connected
+	BannedAt time.Time
 }
 
 // Expired tests if the ban is already expired.
 func (b *Ban) Expired() bool {
-	return time.Now().After(b.ExpiresAt)
+	return !b.Permanent() && time.Now().After(b.ExpiresAt)
+}
+
+// Permanent returns true if the ban never expires.
+func (b *Ban) Permanent() bool {
+	return b.ExpiresAt.IsZero()
+}
+
+// Range returns the banned IP range, if the ban is not a single IP ban.
+func (b *Ban) Range() (IPRange, bool) {
+	r, err := ParseIPRange(b.Player.IP)
+	if err != nil || r.Single() {
+		return IPRange{}, false
+	}
+	return r, true
+}
+
+// formatBanDuration formats a ban duration, a non positive duration is a permanent ban.
+func formatBanDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return "life"
+	}
+	return duration.Round(time.Second).String()
 }
 
 // BanServer handles the ban parsing
+// A single timer removes the bans when they expire, even if the server's expiry message is missed.
 type BanServer struct {
 	mu      sync.Mutex
 	BanList []Ban
+
+	timer     *time.Timer
+	onExpire  func(Ban)
+	expiredAt map[string]time.Time
+
+	// keyed by IP and watcher ID
+	watchers      map[string]map[int]context.CancelFunc
+	nextWatcherID int
+}
+
+// SetExpiryHandler sets the function that is called with every ban that expired on time.
+func (b *BanServer) SetExpiryHandler(handler func(Ban)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.onExpire = handler
+}
+
+// WatchContext returns a context that is cancelled as soon as the ban of the IP is removed,
+// either because it expired, the IP was unbanned or banned again.
+func (b *BanServer) WatchContext(parent context.Context, ip string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.watchers == nil {
+		b.watchers = make(map[string]map[int]context.CancelFunc)
+	}
+	if b.watchers[ip] == nil {
+		b.watchers[ip] = make(map[int]context.CancelFunc)
+	}
+	b.nextWatcherID++
+	id := b.nextWatcherID
+	b.watchers[ip][id] = cancel
+
+	// watchers that are done before the ban is removed must not pile up
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.watchers[ip], id)
+		if len(b.watchers[ip]) == 0 {
+			delete(b.watchers, ip)
+		}
+	}()
+	return ctx, cancel
+}
+
+// watcherCount returns the number of contexts that wait for the removal of bans.
+func (b *BanServer) watcherCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, watchers := range b.watchers {
+		count += len(watchers)
+	}
+	return count
+}
+
+// ExpiredRecently returns true once, if the ban of the IP expired on time,
+// meaning that the server's expiry message can be ignored.
+func (b *BanServer) ExpiredRecently(ip string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	expiredAt, ok := b.expiredAt[ip]
+	delete(b.expiredAt, ip)
+	return ok && time.Since(expiredAt) <= expiredBanMemory
+}
+
+// stopWatching cancels the watchers of the IP, must be called while holding the lock.
+func (b *BanServer) stopWatching(ip string) {
+	for _, cancel := range b.watchers[ip] {
+		cancel()
+	}
+	delete(b.watchers, ip)
+}
+
+// schedule resets the timer to the next expiring ban, must be called while holding the lock.
+func (b *BanServer) schedule() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	next := time.Time{}
+	for _, ban := range b.BanList {
+		if !ban.Permanent() && (next.IsZero() || ban.ExpiresAt.Before(next)) {
+			next = ban.ExpiresAt
+		}
+	}
+
+	if !next.IsZero() {
+		b.timer = time.AfterFunc(time.Until(next), b.expire)
+	}
+}
+
+// expire removes all expired bans and calls the expiry handler.
+func (b *BanServer) expire() {
+	b.mu.Lock()
+
+	now := time.Now()
+	if b.expiredAt == nil {
+		b.expiredAt = make(map[string]time.Time)
+	}
+	for ip, expiredAt := range b.expiredAt {
+		if now.Sub(expiredAt) > expiredBanMemory {
+			delete(b.expiredAt, ip)
+		}
+	}
+
+	expired := make([]Ban, 0, 1)
+	active := b.BanList[:0]
+	for _, ban := range b.BanList {
+		if ban.Expired() {
+			expired = append(expired, ban)
+			b.expiredAt[ban.Player.IP] = now
+			b.stopWatching(ban.Player.IP)
+		} else {
+			active = append(active, ban)
+		}
+	}
+	b.BanList = active
+	b.schedule()
+
+	onExpire := b.onExpire
+	b.mu.Unlock()
+
+	if onExpire == nil {
+		return
+	}
+	for _, ban := range expired {
+		onExpire(ban)
+	}
 }
 
 // GetIDFrom IP looks for the banlist index based on IP
@@ -50,19 +220,30 @@ func (b *BanServer) GetIDFrom(ip string) (id int, ok bool) {
 }
 
 // Ban a player for a specific time and reason.
+// A non positive duration bans the player permanently.
 func (b *BanServer) Ban(p Player, duration time.Duration, reason string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	now := time.Now()
+	expiresAt := time.Time{}
+	if duration > 0 {
+		expiresAt = now.Add(duration)
+	}
+
+	defer b.schedule()
+
 	// overwrite existing ban
 	for idx, currBan := range b.BanList {
 		if currBan.Player.IP == p.IP {
+			b.stopWatching(p.IP)
 			b.BanList[idx] = Ban{
 				Player:    p,
-				ExpiresAt: time.Now().Add(duration),
+				ExpiresAt: expiresAt,
 				Reason:    reason,
+				BannedAt:  now,
 			}
-			sort.Sort(byBantime(b.BanList))
+			sort.Stable(byBantime(b.BanList))
 			return
 		}
 	}
@@ -70,10 +251,39 @@ func (b *BanServer) Ban(p Player, duration time.Duration, reason string) {
 	// add new ban
 	b.BanList = append(b.BanList, Ban{
 		Player:    p,
-		ExpiresAt: time.Now().Add(duration),
+		ExpiresAt: expiresAt,
 		Reason:    reason,
+		BannedAt:  now,
 	})
-	sort.Sort(byBantime(b.BanList))
+	sort.Stable(byBantime(b.BanList))
+}
+
+// Replace replaces the ban list with the passed bans, keeping their order.
+// The ban time of already known bans is kept.
+func (b *BanServer) Replace(bans []Ban) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bannedAt := make(map[string]time.Time, len(b.BanList))
+	for _, ban := range b.BanList {
+		bannedAt[ban.Player.IP] = ban.BannedAt
+	}
+
+	b.BanList = make([]Ban, len(bans))
+	copy(b.BanList, bans)
+
+	for idx, ban := range b.BanList {
+		if ban.BannedAt.IsZero() {
+			b.BanList[idx].BannedAt = bannedAt[ban.Player.IP]
+		}
+		delete(bannedAt, ban.Player.IP)
+	}
+
+	// bans that are gone on the server
+	for ip := range bannedAt {
+		b.stopWatching(ip)
+	}
+	b.schedule()
 }
 
 // GetBan by ID
@@ -88,6 +298,20 @@ func (b *BanServer) GetBan(id int) (foundBan Ban, err error) {
 	return b.BanList[id], nil
 }
 
+// GetBanByIP returns the ban of a specific IP.
+func (b *BanServer) GetBanByIP(ip string) (Ban, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ban := range b.BanList {
+		if ban.Player.IP == ip {
+			return ban, true
+		}
+	}
+
+	return Ban{}, false
+}
+
 // GetBanByNameAndReason returns a ban that has a specific reason and a specific player name.
 func (b *BanServer) GetBanByNameAndReason(name, reason string) (bb Ban, ok bool) {
 	b.mu.Lock()
@@ -132,6 +356,8 @@ func (b *BanServer) UnbanIP(ip string) (Ban, error) {
 	if position >= 0 {
 		ban := b.BanList[position]
 		b.BanList = append(b.BanList[:position], b.BanList[position+1:]...)
+		b.stopWatching(ip)
+		b.schedule()
 		return ban, nil
 	}
 
@@ -149,6 +375,8 @@ func (b *BanServer) UnbanIndex(index int) (Ban, error) {
 
 	ban := b.BanList[index]
 	b.BanList = append(b.BanList[:index], b.BanList[index+1:]...)
+	b.stopWatching(ban.Player.IP)
+	b.schedule()
 	return ban, nil
 }
 
@@ -157,7 +385,11 @@ func (b *BanServer) UnbanAll() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	for _, ban := range b.BanList {
+		b.stopWatching(ban.Player.IP)
+	}
 	b.BanList = b.BanList[:0]
+	b.schedule()
 }
 
 // Bans returns a list of all bans.
@@ -184,16 +416,27 @@ func (b *BanServer) String() string {
 	sb := strings.Builder{}
 
 	for idx, ban := range bans {
-		banTimeLeftSeconds := time.Until(ban.ExpiresAt).Round(time.Second)
-		name := ban.Player.Name
-		reason := ban.Reason
-		sb.WriteString(fmt.Sprintf("idx=%-2d %9s '%s' (%s)\n", idx, banTimeLeftSeconds, name, reason))
+		sb.WriteString(ban.Format(idx))
 	}
 
 	return sb.String()
 
 }
 
+// Format formats the ban as a line of the ban list with the passed index.
+func (b *Ban) Format(idx int) string {
+	banTimeLeft := "permanent"
+	if !b.Permanent() {
+		banTimeLeft = time.Until(b.ExpiresAt).Round(time.Second).String()
+	}
+	name := b.Player.Name
+	reason := b.Reason
+	if r, ok := b.Range(); ok {
+		return fmt.Sprintf("idx=%-2d %9s '%s' [%s IPs] (%s)\n", idx, banTimeLeft, name, r.Size(), reason)
+	}
+	return fmt.Sprintf("idx=%-2d %9s '%s' (%s)\n", idx, banTimeLeft, name, reason)
+}
+
 func newBanServer() BanServer {
 	return BanServer{BanList: make([]Ban, 0, 8)}
 }
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type password string
+
+// Address used for ips:port of servers
+type Address string
+
+type command struct {
+	Author  string
+	Command string
+}
+
+// Bot moderates Teeworlds servers in Discord channels.
+// It is created with New, started with Run and released with Close.
+type Bot struct {
+	EconPasswords            map[Address]password
+	Parsers                  map[Address]LineParser
+	CommandAddresses         map[Address]Address // econ endpoints of servers that are moderated via their log file
+	ServerStates             map[Address]*Server
+	ChannelAddress           ChannelAddressMap
+	ServerRoutines           RoutineMap
+	DiscordToken             string
+	DiscordAdmin             string
+	DiscordModerators        userSet
+	SpiedOnPlayers           userSet
+	JoinNotify               *NotifyMap
+	DiscordModeratorCommands commandSet
+	DiscordModeratorRole     string
+	MentionLimiter           map[Address]*RateLimiter
+	DiscordCommandQueue      map[Address]chan command
+	AnnouncemenServers       map[Address]*AnnouncementServer
+	LogLevel                 int // 0 : chat & votes & rcon,  1: & whisper, 2: & join & leave
+
+	stateMu   sync.Mutex
+	StateFile string // empty value disables the persistence of the channel bindings
+
+	BanReplacementIDCommand string // format string
+	BanReplacementIPCommand string // format string
+	BanEscalation           BanEscalation
+
+	NicknameTracker *NicknameTracker
+	BanHistory      *BanHistory
+	GlobalBans      *GlobalBanList
+
+	PendingRangeBans PendingRangeBans
+	BansPages        BansPagesMap
+	PendingButtons   PendingButtonsMap
+	Appeals          AppealMap
+	AppealsChannel   string
+
+	// canceled when the bot shuts down, stops all server routines
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// ServerOptions configure a server that is moderated by the bot.
+type ServerOptions struct {
+	Address  Address // IP:Port of the external console or file:/path/to/server.log
+	Password string
+	Profile  string // game mod of the server, zCatch by default
+
+	// econ endpoint that executes the commands of a server that is moderated via its log file
+	CommandAddress Address
+}
+
+// Options configure a Bot, empty values are replaced by their defaults.
+type Options struct {
+	DiscordToken         string
+	DiscordAdmin         string
+	DiscordModerators    []string
+	DiscordModeratorRole string
+	ModeratorCommands    []string      // in addition to the default moderator commands
+	MentionDelay         time.Duration // 5 minutes by default
+
+	Servers  []ServerOptions
+	LogLevel int
+
+	// e.g. "ban {ID} {minutes} {reason}", {minutes} is replaced by the escalated ban duration,
+	// {reason} by the reason of ?punish or the default ban reason
+	BanIDCommand  string
+	BanIPCommand  string
+	BanEscalation BanEscalation
+
+	// the bot takes ownership of these, nil disables the nickname tracking and the ban history,
+	// the global bans are kept in memory by default.
+	NicknameTracker *NicknameTracker
+	BanHistory      *BanHistory
+	GlobalBans      *GlobalBanList
+
+	StateFile      string // empty value disables the persistence of the channel bindings
+	AppealsChannel string
+}
+
+var (
+	// ErrDiscordConnection is returned by Run if the bot cannot connect to Discord.
+	ErrDiscordConnection = errors.New("could not establish a connection to the discord api, please check your credentials")
+
+	defaultModeratorCommands = []string{
+		"help",
+		"status",
+		"bans",
+		"multiban",
+		"multiunban",
+		"notify",
+		"unnotify",
+		"whois",
+		"banhistory",
+		"punish",
+		"globalbans",
+		"globalban",
+		"localban",
+	}
+)
+
+const (
+	defaultMentionDelay = 5 * time.Minute
+
+	// how long a command waits for the command queue of a server that is busy
+	commandQueueTimeout = 10 * time.Second
+)
+
+// newBot creates an empty Bot without any servers.
+func newBot() *Bot {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bot{
+		EconPasswords:            make(map[Address]password),
+		Parsers:                  make(map[Address]LineParser),
+		CommandAddresses:         make(map[Address]Address),
+		ServerStates:             make(map[Address]*Server),
+		ChannelAddress:           newChannelAddressMap(),
+		ServerRoutines:           newRoutineMap(),
+		DiscordModerators:        newUserSet(),
+		SpiedOnPlayers:           newUserSet(),
+		JoinNotify:               newNotifyMap(),
+		DiscordModeratorCommands: newCommandSet(),
+		DiscordCommandQueue:      make(map[Address]chan command),
+		AnnouncemenServers:       make(map[Address]*AnnouncementServer),
+		MentionLimiter:           make(map[Address]*RateLimiter),
+		ctx:                      ctx,
+		cancel:                   cancel,
+	}
+}
+
+// New creates a Bot that moderates the configured servers as soon as they are bound to a channel.
+func New(opts Options) (*Bot, error) {
+	if opts.DiscordToken == "" {
+		return nil, errors.New("no discord token specified")
+	}
+	if opts.DiscordAdmin == "" {
+		return nil, errors.New("no discord admin specified")
+	}
+	if len(opts.Servers) == 0 {
+		return nil, errors.New("no servers specified")
+	}
+
+	b := newBot()
+	b.DiscordToken = opts.DiscordToken
+	b.DiscordAdmin = opts.DiscordAdmin
+	b.DiscordModerators.Add(opts.DiscordAdmin)
+	for _, moderator := range opts.DiscordModerators {
+		b.DiscordModerators.Add(moderator)
+	}
+	b.DiscordModeratorRole = opts.DiscordModeratorRole
+
+	for _, cmd := range opts.ModeratorCommands {
+		b.DiscordModeratorCommands.Add(cmd)
+	}
+	for _, cmd := range defaultModeratorCommands {
+		b.DiscordModeratorCommands.Add(cmd)
+	}
+
+	mentionDelay := opts.MentionDelay
+	if mentionDelay <= 0 {
+		mentionDelay = defaultMentionDelay
+	}
+
+	b.LogLevel = opts.LogLevel
+	b.NicknameTracker = opts.NicknameTracker
+	b.BanHistory = opts.BanHistory
+	b.GlobalBans = opts.GlobalBans
+	if b.GlobalBans == nil {
+		b.GlobalBans, _ = NewGlobalBanList("")
+	}
+
+	for _, server := range opts.Servers {
+		if err := b.addServer(server, mentionDelay); err != nil {
+			return nil, err
+		}
+	}
+
+	b.BanReplacementIDCommand = banCommandFormat(opts.BanIDCommand, "ID", "%d")
+	b.BanReplacementIPCommand = banCommandFormat(opts.BanIPCommand, "IP", "%s")
+
+	b.BanEscalation = opts.BanEscalation
+	if len(b.BanEscalation) == 0 {
+		b.BanEscalation = defaultBanEscalation
+	}
+
+	b.StateFile = opts.StateFile
+	b.AppealsChannel = opts.AppealsChannel
+	return b, nil
+}
+
+// addServer registers a server that can be bound to a channel.
+func (b *Bot) addServer(opts ServerOptions, mentionDelay time.Duration) error {
+	addr := opts.Address
+	if addr == "" {
+		return errors.New("empty server address")
+	}
+	if _, ok := b.ServerStates[addr]; ok {
+		return fmt.Errorf("server %s is specified multiple times", addr)
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = defaultParserProfile
+	}
+	parser, err := GetParser(profile)
+	if err != nil {
+		return err
+	}
+
+	if opts.CommandAddress != "" {
+		if _, isFile := addr.LogFile(); !isFile {
+			return fmt.Errorf("command addresses can only be used with log files: %s", addr)
+		}
+		b.CommandAddresses[addr] = opts.CommandAddress
+	}
+
+	b.EconPasswords[addr] = password(opts.Password)
+	b.Parsers[addr] = parser
+
+	srv := NewServer()
+	srv.JoinNotify = b.JoinNotify
+	srv.NicknameTracker = b.NicknameTracker
+	b.ServerStates[addr] = srv
+
+	srv.AddJoinHandler(func(p Player) {
+		b.NicknameTracker.Add(p)
+	})
+
+	srv.AddBanHandler(func(e BanEvent) {
+		e.Server = addr
+		if err := b.BanHistory.Add(e); err != nil {
+			log.Printf("error while adding ban event to the history: %s", err.Error())
+		}
+
+		// unbanned players are not banned again when the server reconnects
+		if e.Type == BanEventUnban {
+			b.GlobalBans.Remove(e.Player.IP)
+		}
+	})
+
+	srv.AddBanListHandler(func(bans []Ban) {
+		go b.enforceGlobalBans(addr, bans)
+	})
+
+	b.DiscordCommandQueue[addr] = make(chan command)
+	b.MentionLimiter[addr] = NewRateLimiter(mentionDelay)
+	return nil
+}
+
+// banCommandFormat converts a ban command template with an {ID} or {IP} placeholder into a format string.
+// Templates without the placeholder are replaced by the default ban command.
+func banCommandFormat(template, placeholder, verb string) string {
+	upper := "{" + placeholder + "}"
+	lower := "{" + strings.ToLower(placeholder) + "}"
+	if !strings.Contains(template, upper) && !strings.Contains(template, lower) {
+		return "ban " + verb + " " + minutesPlaceholder + " " + reasonPlaceholder
+	}
+
+	template = strings.Replace(template, upper, verb, 1)
+	template = strings.Replace(template, lower, verb, 1)
+	template = strings.Replace(template, "{minutes}", minutesPlaceholder, 1)
+	return strings.Replace(template, "{reason}", reasonPlaceholder, 1)
+}
+
+// Run connects the bot to Discord and resumes moderating the servers that were bound to channels before.
+// Run blocks until the passed context is canceled.
+func (b *Bot) Run(ctx context.Context) error {
+	dg, err := discordgo.New("Bot " + b.DiscordToken)
+	if err != nil {
+		return err
+	}
+
+	// commands are read from the message content
+	dg.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent
+
+	// slash commands and buttons
+	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		b.InteractionCreateHandler(s, i)
+	})
+
+	dg.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author.ID == s.State.User.ID {
+			return
+		}
+
+		// direct messages
+		if m.GuildID == "" {
+			b.DirectMessageHandler(s, m)
+			return
+		}
+
+		b.CommandMessageHandler(s, m)
+	})
+
+	err = dg.Open()
+	if err != nil {
+		return ErrDiscordConnection
+	}
+	defer dg.Close()
+
+	b.resumeBindings(dg)
+
+	// text commands keep working if the slash commands cannot be registered
+	if err := b.RegisterApplicationCommands(dg, dg.State.User.ID); err != nil {
+		log.Printf("error while registering the slash commands: %s", err.Error())
+	}
+
+	log.Println("Bot is now running.")
+	select {
+	case <-ctx.Done():
+	case <-b.ctx.Done():
+	}
+
+	// stop all server routines
+	b.cancel()
+	return nil
+}
+
+// resumeBindings resumes moderating the servers that were bound to channels before the restart.
+func (b *Bot) resumeBindings(s DiscordSession) {
+	if b.StateFile == "" {
+		return
+	}
+
+	bindings, err := LoadBindings(b.StateFile)
+	if err != nil {
+		log.Printf("error while loading channel bindings from %s: %s", b.StateFile, err.Error())
+	}
+
+	for _, binding := range bindings {
+		pass, ok := b.EconPasswords[binding.Address]
+		if !ok {
+			log.Printf("unknown server address in %s: %s", b.StateFile, binding.Address)
+			continue
+		}
+
+		if b.ChannelAddress.AlreadyRegistered(binding.Address) {
+			log.Printf("the address %s is already registered with a channel.", binding.Address)
+			continue
+		}
+		b.ChannelAddress.Set(binding)
+
+		b.startServerRoutine(s, binding.Address, pass)
+		s.ChannelMessageSend(string(binding.ChannelID), fmt.Sprintf("Resumed listening to server %s", binding.Address))
+	}
+}
+
+// queueCommand passes the command to the command queue of a moderated server.
+// False is returned if the server is not moderated (anymore), the bot is closed or the queue is not processed in time.
+func (b *Bot) queueCommand(addr Address, cmd command) bool {
+	queue, ok := b.DiscordCommandQueue[addr]
+	if !ok || !b.ChannelAddress.AlreadyRegistered(addr) {
+		return false
+	}
+
+	timer := time.NewTimer(commandQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- cmd:
+		return true
+	case <-b.ServerRoutines.Done(addr):
+		// stopped by #unmoderate
+		return false
+	case <-b.ctx.Done():
+		return false
+	case <-timer.C:
+		log.Printf("timed out while queueing the command of %s on %s: %s", cmd.Author, addr, cmd.Command)
+		return false
+	}
+}
+
+// queueCommandOnAllServers passes the command to the command queues of all moderated servers.
+func (b *Bot) queueCommandOnAllServers(cmd command) {
+	for _, addr := range b.ChannelAddress.GetAddresses() {
+		b.queueCommand(addr, cmd)
+	}
+}
+
+func (b *Bot) GetServers() []*Server {
+	addresses := b.ChannelAddress.GetAddresses()
+
+	servers := make([]*Server, 0, len(addresses))
+
+	for _, addr := range addresses {
+		servers = append(servers, b.ServerStates[addr])
+	}
+	return servers
+}
+
+func (b *Bot) GetAddressByChannelID(channelID string) (Address, bool) {
+	return b.ChannelAddress.Get(discordChannel(channelID))
+}
+
+// GetChannelIDByAddress returns the ID of the channel that the server is currently bound to.
+func (b *Bot) GetChannelIDByAddress(addr Address) (string, bool) {
+	channel, ok := b.ChannelAddress.GetChannel(addr)
+	return string(channel), ok
+}
+
+// SaveBindings persists the current channel bindings to the state file.
+func (b *Bot) SaveBindings() {
+	if b.StateFile == "" {
+		return
+	}
+
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+
+	err := SaveBindings(b.StateFile, b.ChannelAddress.Bindings())
+	if err != nil {
+		log.Printf("error while saving channel bindings to %s: %s", b.StateFile, err.Error())
+	}
+}
+
+func (b *Bot) GetServerByChannelID(channelID string) (*Server, bool) {
+	addr, ok := b.ChannelAddress.Get(discordChannel(channelID))
+	if !ok {
+		return nil, ok
+	}
+
+	server, ok := b.ServerStates[addr]
+	if !ok {
+		return nil, ok
+	}
+	return server, true
+}
+
+func (b *Bot) GetAnnouncementServerByChannelID(channelID string) (*AnnouncementServer, bool) {
+	addr, ok := b.ChannelAddress.Get(discordChannel(channelID))
+	if !ok {
+		return nil, ok
+	}
+
+	as, ok := b.AnnouncemenServers[addr]
+	if !ok {
+		return nil, ok
+	}
+	return as, true
+}
+
+func (b *Bot) AllowMention(channelID string) (allow bool) {
+	addr, ok := b.ChannelAddress.Get(discordChannel(channelID))
+	if !ok {
+		return false
+	}
+
+	ml, ok := b.MentionLimiter[addr]
+	if !ok {
+		return false
+	}
+
+	return ml.Allow()
+
+}
+
+// Close stops all server routines and releases the resources of the bot.
+func (b *Bot) Close() {
+	// the command queues are not closed, as handlers and routines might still send commands.
+	// Their consumers stop as soon as the context is cancelled, which lets queueCommand give up.
+	b.cancel()
+
+	if err := b.BanHistory.Close(); err != nil {
+		log.Printf("error while closing the ban history: %s", err.Error())
+	}
+}
+
+func (b *Bot) String() string {
+	sb := strings.Builder{}
+
+	sb.WriteString("==================== Configuration ====================\n")
+
+	sb.WriteString("EconPasswords:\n")
+	for addr, pass := range b.EconPasswords {
+		sb.WriteString(fmt.Sprintf("\t%s : %s\n", addr, pass))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(fmt.Sprintf("DiscordToken : %s\n", b.DiscordToken))
+	sb.WriteString("\n")
+
+	sb.WriteString("Ban Replacement ID: " + b.BanReplacementIDCommand + "\n")
+	sb.WriteString("Ban Replacement IP: " + b.BanReplacementIPCommand + "\n")
+	sb.WriteString("Ban Escalation: " + b.BanEscalation.String() + "\n")
+	sb.WriteString("\n\n")
+
+	sb.WriteString(fmt.Sprintf("Administrator: \n\t%s\n\n", b.DiscordAdmin))
+
+	sb.WriteString("Moderators:\n")
+	for _, mod := range b.DiscordModerators.Users() {
+		sb.WriteString(fmt.Sprintf("\t%s\n", mod))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("Allowed Commands:\n")
+	for _, cmd := range b.DiscordModeratorCommands.Commands() {
+		sb.WriteString(fmt.Sprintf("\t%s\n", cmd))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("Nickname Tracking: ")
+	nickTrack := "enabled"
+	if b.NicknameTracker == nil {
+		nickTrack = "disabled"
+	}
+	sb.WriteString(nickTrack)
+	sb.WriteString("\n")
+
+	sb.WriteString("Ban History: ")
+	banHistory := "enabled"
+	if b.BanHistory == nil {
+		banHistory = "disabled"
+	}
+	sb.WriteString(banHistory)
+	sb.WriteString("\n")
+
+	if b.GlobalBans != nil {
+		sb.WriteString(fmt.Sprintf("Global Bans: %d\n", len(b.GlobalBans.Bans())))
+	}
+
+	sb.WriteString(fmt.Sprintf("LogLevel: %d\n", b.LogLevel))
+	sb.WriteString(fmt.Sprintf("StateFile: %s\n", b.StateFile))
+	sb.WriteString("\n")
+
+	sb.WriteString("========================================================\n")
+	return sb.String()
+}
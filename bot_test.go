@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	opts := Options{
+		DiscordToken: "token",
+		DiscordAdmin: "admin#0001",
+		Servers: []ServerOptions{
+			{Address: "127.0.0.1:9303", Password: "pw"},
+			{Address: "file:/srv/teeworlds/server.log", Profile: vanilla06Profile.Name, CommandAddress: "127.0.0.1:9304"},
+		},
+		BanIDCommand: "ban {id} {minutes} griefing",
+	}
+
+	b, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if !b.DiscordModerators.Contains("admin#0001") {
+		t.Errorf("the admin must be a moderator")
+	}
+	if !b.DiscordModeratorCommands.Contains("status") {
+		t.Errorf("the default moderator commands are missing")
+	}
+	if b.BanReplacementIDCommand != "ban %d "+minutesPlaceholder+" griefing" {
+		t.Errorf("unexpected ban id command: %s", b.BanReplacementIDCommand)
+	}
+	if b.BanReplacementIPCommand != "ban %s "+minutesPlaceholder+" "+reasonPlaceholder {
+		t.Errorf("unexpected ban ip command: %s", b.BanReplacementIPCommand)
+	}
+	if b.Parsers["file:/srv/teeworlds/server.log"] != vanilla06Profile {
+		t.Errorf("the server must be parsed with the %s profile", vanilla06Profile.Name)
+	}
+	if b.CommandAddresses["file:/srv/teeworlds/server.log"] != "127.0.0.1:9304" {
+		t.Errorf("command address is missing")
+	}
+
+	invalid := map[string]Options{
+		"no token":    {DiscordAdmin: "admin#0001", Servers: opts.Servers},
+		"no admin":    {DiscordToken: "token", Servers: opts.Servers},
+		"no servers":  {DiscordToken: "token", DiscordAdmin: "admin#0001"},
+		"bad profile": {DiscordToken: "token", DiscordAdmin: "admin#0001", Servers: []ServerOptions{{Address: "127.0.0.1:9303", Profile: "unknown"}}},
+		"econ command address": {DiscordToken: "token", DiscordAdmin: "admin#0001", Servers: []ServerOptions{
+			{Address: "127.0.0.1:9303", CommandAddress: "127.0.0.1:9304"},
+		}},
+	}
+
+	for name, o := range invalid {
+		if _, err := New(o); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBot_Close(t *testing.T) {
+	b, err := New(Options{
+		DiscordToken: "token",
+		DiscordAdmin: testAdmin.String(),
+		Servers:      []ServerOptions{{Address: testAddress, Password: "pw"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ChannelAddress.Set(ChannelBinding{
+		ChannelID: testChannelID,
+		Address:   testAddress,
+	})
+	b.Close()
+
+	// nobody processes the command queue anymore, the handler must not block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendCommand(b, testAdmin, "#vote no")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(commandQueueTimeout / 2):
+		t.Fatal("handler blocked after the bot was closed")
+	}
+}
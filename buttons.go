@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	voteYesButtonID = "vote_yes"
+	voteNoButtonID  = "vote_no"
+	voteBanButtonID = "vote_ban"
+	unbanButtonID   = "unban"
+
+	// clicks that are not handled in time are dropped
+	buttonClickBufferSize = 16
+)
+
+// buttonClick is the click of a moderator on a button of a vote or ban message.
+type buttonClick struct {
+	Author   string
+	CustomID string
+}
+
+// PendingButtonsMap routes the button clicks on vote and ban messages to the routines
+// that wait for them, keyed by the message ID.
+type PendingButtonsMap struct {
+	mu       sync.Mutex
+	messages map[string]chan buttonClick
+}
+
+// Watch registers the message until the context is done and returns the clicks on its buttons.
+// Pass a context with a timeout in order to stop waiting for clicks after some time.
+func (m *PendingButtonsMap) Watch(ctx context.Context, messageID string) <-chan buttonClick {
+	clicks := make(chan buttonClick, buttonClickBufferSize)
+
+	m.mu.Lock()
+	if m.messages == nil {
+		m.messages = make(map[string]chan buttonClick)
+	}
+	m.messages[messageID] = clicks
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// the message might have been registered again
+		if m.messages[messageID] == clicks {
+			delete(m.messages, messageID)
+		}
+	}()
+	return clicks
+}
+
+// Len returns the number of messages that wait for clicks.
+func (m *PendingButtonsMap) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.messages)
+}
+
+// Dispatch passes the click to the routine that waits for clicks on the message.
+// False is returned if no routine waits for the message anymore.
+func (m *PendingButtonsMap) Dispatch(messageID string, click buttonClick) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clicks, ok := m.messages[messageID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case clicks <- click:
+		return true
+	default:
+		return false
+	}
+}
+
+// voteComponents creates the buttons that force a vote.
+// Kick and spectator votes can also be aborted by banning the voting player.
+func voteComponents(disabled, ban bool) []discordgo.MessageComponent {
+	buttons := []discordgo.MessageComponent{
+		discordgo.Button{
+			Label:    "Yes",
+			Style:    discordgo.SuccessButton,
+			CustomID: voteYesButtonID,
+			Disabled: disabled,
+		},
+		discordgo.Button{
+			Label:    "No",
+			Style:    discordgo.SecondaryButton,
+			CustomID: voteNoButtonID,
+			Disabled: disabled,
+		},
+	}
+	if ban {
+		buttons = append(buttons, discordgo.Button{
+			Label:    "Ban",
+			Style:    discordgo.DangerButton,
+			CustomID: voteBanButtonID,
+			Disabled: disabled,
+		})
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: buttons,
+		},
+	}
+}
+
+// unbanComponents creates the button that removes a ban.
+func unbanComponents(disabled bool) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Unban",
+					Style:    discordgo.SecondaryButton,
+					CustomID: unbanButtonID,
+					Disabled: disabled,
+				},
+			},
+		},
+	}
+}
+
+// messageComponents returns the buttons of the message of an event, nil if the event has no buttons.
+func messageComponents(event Event) []discordgo.MessageComponent {
+	switch e := event.(type) {
+	case VoteStartEvent:
+		return voteComponents(false, e.Type != VoteOption)
+	case BanEvent:
+		if e.Type == BanEventBan {
+			return unbanComponents(false)
+		}
+	}
+	return nil
+}
+
+// hasButton returns true if the components of a message contain the button with the custom ID.
+// Messages that were received from Discord contain pointers to their components.
+func hasButton(components []discordgo.MessageComponent, customID string) bool {
+	for _, component := range components {
+		switch c := component.(type) {
+		case discordgo.ActionsRow:
+			if hasButton(c.Components, customID) {
+				return true
+			}
+		case *discordgo.ActionsRow:
+			if hasButton(c.Components, customID) {
+				return true
+			}
+		case discordgo.Button:
+			if c.CustomID == customID {
+				return true
+			}
+		case *discordgo.Button:
+			if c.CustomID == customID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// disableButtons disables the buttons of a vote or ban message that cannot be used anymore.
+func disableButtons(s DiscordSession, msg *discordgo.Message, components []discordgo.MessageComponent) {
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+		Components: &components,
+	})
+	if err != nil {
+		log.Printf("error while disabling the buttons of a message: %s", err.Error())
+	}
+}
+
+// ModerationButtonHandler passes the click on a vote or ban button to the routine that waits for it.
+// The buttons are disabled after the first click, as well as the buttons of votes that already ended.
+func (b *Bot) ModerationButtonHandler(s DiscordSession, i *discordgo.InteractionCreate, customID string) {
+	b.PendingButtons.Dispatch(i.Message.ID, buttonClick{
+		Author:   interactionAuthor(i),
+		CustomID: customID,
+	})
+
+	components := voteComponents(true, hasButton(i.Message.Components, voteBanButtonID))
+	if customID == unbanButtonID {
+		components = unbanComponents(true)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("error while disabling the buttons of a message: %s", err.Error())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPendingButtonsMap(t *testing.T) {
+	var m PendingButtonsMap
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clicks := m.Watch(ctx, "1")
+
+	if m.Dispatch("2", buttonClick{CustomID: voteYesButtonID}) {
+		t.Errorf("clicks on other messages must not be routed")
+	}
+	if !m.Dispatch("1", buttonClick{CustomID: voteNoButtonID}) {
+		t.Fatal("click was not routed to the message")
+	}
+
+	if click := <-clicks; click.CustomID != voteNoButtonID {
+		t.Errorf("expected %s, got %s", voteNoButtonID, click.CustomID)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for m.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if m.Len() != 0 {
+		t.Errorf("message must be removed as soon as its context is done")
+	}
+	if m.Dispatch("1", buttonClick{CustomID: voteYesButtonID}) {
+		t.Errorf("clicks after the timeout must not be routed")
+	}
+}
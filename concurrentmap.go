@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"sort"
 	"sync"
 )
 
 type discordChannel string
 
+// ChannelBinding associates a discord channel with a server address.
+type ChannelBinding struct {
+	ChannelID discordChannel `json:"channel_id"`
+	GuildID   string         `json:"guild_id"`
+	Address   Address        `json:"address"`
+
+	// the log cleanup does not touch messages before this message
+	MessageID string `json:"message_id"`
+}
+
 // ChannelAddressMap maps a discord channel to a server address
 type ChannelAddressMap struct {
 	mu sync.Mutex
-	m  map[discordChannel]Address
+	m  map[discordChannel]ChannelBinding
 }
 
 func newChannelAddressMap() ChannelAddressMap {
-	return ChannelAddressMap{m: make(map[discordChannel]Address)}
+	return ChannelAddressMap{m: make(map[discordChannel]ChannelBinding)}
 }
 
 // GetAddresses returns a sorted list of all actively mapped addresses
@@ -22,8 +33,8 @@ func (a *ChannelAddressMap) GetAddresses() []Address {
 	a.mu.Lock()
 	result := make([]Address, 0, len(a.m))
 
-	for _, addr := range a.m {
-		result = append(result, addr)
+	for _, binding := range a.m {
+		result = append(result, binding.Address)
 	}
 	a.mu.Unlock()
 
@@ -31,12 +42,28 @@ func (a *ChannelAddressMap) GetAddresses() []Address {
 	return result
 }
 
+// Bindings returns a list of all channel bindings sorted by address
+func (a *ChannelAddressMap) Bindings() []ChannelBinding {
+	a.mu.Lock()
+	result := make([]ChannelBinding, 0, len(a.m))
+
+	for _, binding := range a.m {
+		result = append(result, binding)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
+
 // Set connects a discord channel ID with a server Address
-func (a *ChannelAddressMap) Set(channelID discordChannel, addr Address) {
+func (a *ChannelAddressMap) Set(binding ChannelBinding) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.m[channelID] = addr
+	a.m[binding.ChannelID] = binding
 }
 
 // Get returns the Address that is associated with the used channel.
@@ -44,8 +71,43 @@ func (a *ChannelAddressMap) Get(channelID discordChannel) (Address, bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	addr, ok := a.m[channelID]
-	return addr, ok
+	binding, ok := a.m[channelID]
+	return binding.Address, ok
+}
+
+// GetBinding returns the channel binding of the server address.
+func (a *ChannelAddressMap) GetBinding(addr Address) (ChannelBinding, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, binding := range a.m {
+		if binding.Address == addr {
+			return binding, true
+		}
+	}
+	return ChannelBinding{}, false
+}
+
+// GetChannel returns the channel that the server address is associated with.
+func (a *ChannelAddressMap) GetChannel(addr Address) (discordChannel, bool) {
+	binding, ok := a.GetBinding(addr)
+	return binding.ChannelID, ok
+}
+
+// Move associates an already registered server address with a different channel.
+// Returns the previously associated channel.
+func (a *ChannelAddressMap) Move(binding ChannelBinding) (previous discordChannel, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for channel, current := range a.m {
+		if current.Address == binding.Address {
+			delete(a.m, channel)
+			a.m[binding.ChannelID] = binding
+			return channel, true
+		}
+	}
+	return "", false
 }
 
 // RemoveAddress removes server address from mapping
@@ -53,8 +115,8 @@ func (a *ChannelAddressMap) RemoveAddress(addr Address) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	for key, value := range a.m {
-		if value == addr {
+	for key, binding := range a.m {
+		if binding.Address == addr {
 			delete(a.m, key)
 			break
 		}
@@ -74,11 +136,68 @@ func (a *ChannelAddressMap) AlreadyRegistered(addr Address) (found bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	for _, serverAddr := range a.m {
-		if serverAddr == addr {
+	for _, binding := range a.m {
+		if binding.Address == addr {
 			found = true
 			return
 		}
 	}
 	return
 }
+
+// RoutineMap maps a server address to the context of its server routine.
+type RoutineMap struct {
+	mu sync.Mutex
+	m  map[Address]serverRoutineContext
+}
+
+type serverRoutineContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newRoutineMap() RoutineMap {
+	return RoutineMap{m: make(map[Address]serverRoutineContext)}
+}
+
+// Set registers the context of a server routine and its cancel function.
+func (r *RoutineMap) Set(addr Address, ctx context.Context, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.m[addr] = serverRoutineContext{ctx: ctx, cancel: cancel}
+}
+
+// Done returns the done channel of the server routine's context, nil if no routine is registered.
+func (r *RoutineMap) Done(addr Address) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routine, ok := r.m[addr]
+	if !ok {
+		return nil
+	}
+	return routine.ctx.Done()
+}
+
+// Cancel stops the server routine of the passed address.
+func (r *RoutineMap) Cancel(addr Address) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routine, ok := r.m[addr]
+	if !ok {
+		return false
+	}
+	routine.cancel()
+	delete(r.m, addr)
+	return true
+}
+
+// Remove removes the context of a server routine without cancelling it.
+func (r *RoutineMap) Remove(addr Address) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.m, addr)
+}
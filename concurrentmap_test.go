@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestChannelAddressMap_Move(t *testing.T) {
+	m := newChannelAddressMap()
+	m.Set(ChannelBinding{ChannelID: "channel1", Address: "127.0.0.1:9303"})
+
+	if _, ok := m.Move(ChannelBinding{ChannelID: "channel2", Address: "127.0.0.1:9304"}); ok {
+		t.Fatal("unregistered address should not be moved")
+	}
+
+	previous, ok := m.Move(ChannelBinding{ChannelID: "channel2", Address: "127.0.0.1:9303"})
+	if !ok {
+		t.Fatal("registered address should be moved")
+	}
+
+	if previous != "channel1" {
+		t.Fatalf("Expected 'channel1', got '%s'", previous)
+	}
+
+	if _, ok := m.Get("channel1"); ok {
+		t.Fatal("previous channel should not be registered anymore")
+	}
+
+	channel, ok := m.GetChannel("127.0.0.1:9303")
+	if !ok || channel != "channel2" {
+		t.Fatalf("Expected 'channel2', got '%s'", channel)
+	}
+}
@@ -1,15 +1,32 @@
 package main
 
 import (
+	"io"
 	"log"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// DiscordSession is the part of the Discord API that the bot uses.
+// It is implemented by *discordgo.Session and can be replaced in tests.
+type DiscordSession interface {
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelFileSend(channelID, name string, r io.Reader, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+}
+
 // SplitChannelMessageSend properly splits long output in order to accepted by the discord servers.
 // also properly wrap single codeblocks that were split during this process
-func SplitChannelMessageSend(s *discordgo.Session, m *discordgo.MessageCreate, text string) {
+func SplitChannelMessageSend(s DiscordSession, channelID string, text string) {
 	const codeblockDelimiter = "```"
 
 	codeblockFound := strings.Count(text, codeblockDelimiter) == 2
@@ -53,7 +70,7 @@ func SplitChannelMessageSend(s *discordgo.Session, m *discordgo.MessageCreate, t
 			}
 		}
 
-		if _, err := s.ChannelMessageSend(m.ChannelID, chunk); err != nil {
+		if _, err := s.ChannelMessageSend(channelID, chunk); err != nil {
 			log.Println(err)
 		}
 	}
@@ -0,0 +1,406 @@
+// Package discordtest provides a fake Discord session for tests.
+// The session keeps the sent messages and the responses to interactions in memory.
+package discordtest
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// ErrUnknownMessage is returned when a message does not exist in the channel.
+	ErrUnknownMessage = errors.New("unknown message")
+	// ErrTimeout is returned when an awaited message was not sent in time.
+	ErrTimeout = errors.New("timed out")
+)
+
+// Session is a fake Discord session, the bot user sends all messages.
+type Session struct {
+	BotUser *discordgo.User
+
+	mu        sync.Mutex
+	changed   chan struct{} // closed and replaced whenever a message is sent or edited
+	nextID    int
+	messages  map[string][]*discordgo.Message
+	responses map[string]*discordgo.InteractionResponse // interaction ID -> response
+	commands  []*discordgo.ApplicationCommand
+	roles     map[string][]*discordgo.Role
+	files     map[string][]byte
+
+	interactionHandlers []func(*discordgo.InteractionCreate)
+}
+
+// NewSession creates an empty fake session.
+func NewSession() *Session {
+	return &Session{
+		BotUser: &discordgo.User{
+			ID:            "0",
+			Username:      "bot",
+			Discriminator: "0000",
+			Bot:           true,
+		},
+		changed:   make(chan struct{}),
+		nextID:    1,
+		messages:  make(map[string][]*discordgo.Message),
+		responses: make(map[string]*discordgo.InteractionResponse),
+		roles:     make(map[string][]*discordgo.Role),
+		files:     make(map[string][]byte),
+	}
+}
+
+// ChannelMessageSend adds a message to the channel.
+func (s *Session) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{
+		ChannelID: channelID,
+		Content:   content,
+	}), nil
+}
+
+// ChannelMessageSendComplex adds a message with components to the channel.
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Components: data.Components,
+	}), nil
+}
+
+// ChannelFileSend adds a message with an attached file to the channel, the content of the file is kept.
+func (s *Session) ChannelFileSend(channelID, name string, r io.Reader, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.files[name] = data
+	s.mu.Unlock()
+
+	return s.send(&discordgo.Message{
+		ChannelID: channelID,
+		Attachments: []*discordgo.MessageAttachment{{
+			Filename: name,
+			Size:     len(data),
+		}},
+	}), nil
+}
+
+// ChannelMessages returns up to limit messages of the channel, newest first.
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, _ := strconv.Atoi(beforeID)
+	after, _ := strconv.Atoi(afterID)
+
+	result := make([]*discordgo.Message, 0, limit)
+	messages := s.messages[channelID]
+	for idx := len(messages) - 1; idx >= 0 && len(result) < limit; idx-- {
+		id, _ := strconv.Atoi(messages[idx].ID)
+		if (before > 0 && id >= before) || id <= after {
+			continue
+		}
+
+		copied := *messages[idx]
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// ChannelMessageDelete removes a message from the channel.
+func (s *Session) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteMessage(channelID, messageID)
+}
+
+// ChannelMessagesBulkDelete removes multiple messages from the channel.
+func (s *Session) ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range messages {
+		if err := s.deleteMessage(channelID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GuildRoles returns the roles that were set with SetRoles.
+func (s *Session) GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.roles[guildID], nil
+}
+
+// ChannelMessageEditComplex replaces the content and the components of a message.
+func (s *Session) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, ok := s.message(m.Channel, m.ID)
+	if !ok {
+		return nil, ErrUnknownMessage
+	}
+
+	msg := s.messages[m.Channel][idx]
+	if m.Content != nil {
+		msg.Content = *m.Content
+	}
+	if m.Components != nil {
+		msg.Components = *m.Components
+	}
+	s.notify()
+
+	copied := *msg
+	return &copied, nil
+}
+
+// InteractionRespond keeps the response to an interaction,
+// responses that update the message edit the message that was clicked.
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.responses[interaction.ID]; ok {
+		return errors.New("interaction has already been acknowledged")
+	}
+	s.responses[interaction.ID] = resp
+
+	if resp.Type != discordgo.InteractionResponseUpdateMessage || interaction.Message == nil {
+		return nil
+	}
+
+	idx, ok := s.message(interaction.ChannelID, interaction.Message.ID)
+	if !ok {
+		return ErrUnknownMessage
+	}
+
+	msg := s.messages[interaction.ChannelID][idx]
+	msg.Content = resp.Data.Content
+	msg.Components = resp.Data.Components
+	s.notify()
+	return nil
+}
+
+// UserChannelCreate returns the direct message channel of a user, its ID is the ID of the user.
+func (s *Session) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{
+		ID:   recipientID,
+		Type: discordgo.ChannelTypeDM,
+	}, nil
+}
+
+// AddInteractionHandler adds a handler that is called whenever a user clicks a button or uses a slash command,
+// like the InteractionCreate event of the Discord gateway.
+func (s *Session) AddInteractionHandler(handler func(*discordgo.InteractionCreate)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interactionHandlers = append(s.interactionHandlers, handler)
+}
+
+// Click clicks a button of a message as the passed user, e.g. as a moderator.
+// The handlers are called synchronously, the response of the bot is returned.
+func (s *Session) Click(channelID, messageID, customID string, user *discordgo.User) (*discordgo.InteractionResponse, error) {
+	s.mu.Lock()
+	idx, ok := s.message(channelID, messageID)
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrUnknownMessage
+	}
+	msg := *s.messages[channelID][idx]
+	s.mu.Unlock()
+
+	return s.interact(&discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		ChannelID: channelID,
+		Message:   &msg,
+		Member:    &discordgo.Member{User: user},
+		Data: discordgo.MessageComponentInteractionData{
+			CustomID:      customID,
+			ComponentType: discordgo.ButtonComponent,
+		},
+	})
+}
+
+// ExecuteCommand executes a slash command in the channel as the passed user.
+// The handlers are called synchronously, the response of the bot is returned.
+func (s *Session) ExecuteCommand(channelID, name string, user *discordgo.User, options ...*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.InteractionResponse, error) {
+	return s.interact(&discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: channelID,
+		Member:    &discordgo.Member{User: user},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name:        name,
+			CommandType: discordgo.ChatApplicationCommand,
+			Options:     options,
+		},
+	})
+}
+
+// Autocomplete requests the suggestions for the focused option of a slash command that the passed user is typing.
+func (s *Session) Autocomplete(channelID, name string, user *discordgo.User, options ...*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.InteractionResponse, error) {
+	return s.interact(&discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommandAutocomplete,
+		ChannelID: channelID,
+		Member:    &discordgo.Member{User: user},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name:        name,
+			CommandType: discordgo.ChatApplicationCommand,
+			Options:     options,
+		},
+	})
+}
+
+// ApplicationCommandBulkOverwrite replaces the registered slash commands.
+func (s *Session) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands[:0:0], commands...)
+	return commands, nil
+}
+
+// ApplicationCommands returns the registered slash commands.
+func (s *Session) ApplicationCommands() []*discordgo.ApplicationCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append(s.commands[:0:0], s.commands...)
+}
+
+// SetRoles sets the roles of a guild.
+func (s *Session) SetRoles(guildID string, roles ...*discordgo.Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roles[guildID] = roles
+}
+
+// Messages returns the messages of the channel, oldest first.
+func (s *Session) Messages(channelID string) []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]*discordgo.Message, 0, len(s.messages[channelID]))
+	for _, msg := range s.messages[channelID] {
+		copied := *msg
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// WaitForMessage blocks until a message that contains the passed text was sent to the channel.
+// Messages that were sent before the call are considered as well.
+func (s *Session) WaitForMessage(channelID, text string, timeout time.Duration) (*discordgo.Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		for _, msg := range s.messages[channelID] {
+			if strings.Contains(msg.Content, text) {
+				copied := *msg
+				s.mu.Unlock()
+				return &copied, nil
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// File returns the content of an uploaded file.
+func (s *Session) File(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[name]
+	return data, ok
+}
+
+// send adds the message to its channel and returns a copy of it.
+func (s *Session) send(msg *discordgo.Message) *discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.ID = strconv.Itoa(s.nextID)
+	msg.Author = s.BotUser
+	msg.Timestamp = time.Now()
+	s.nextID++
+
+	s.messages[msg.ChannelID] = append(s.messages[msg.ChannelID], msg)
+	s.notify()
+
+	copied := *msg
+	return &copied
+}
+
+// interact passes the interaction to the handlers and returns the response of the bot.
+func (s *Session) interact(interaction *discordgo.Interaction) (*discordgo.InteractionResponse, error) {
+	s.mu.Lock()
+	interaction.ID = "interaction" + strconv.Itoa(s.nextID)
+	interaction.GuildID = "guild"
+	s.nextID++
+	handlers := s.interactionHandlers
+	s.mu.Unlock()
+
+	event := &discordgo.InteractionCreate{Interaction: interaction}
+	for _, handler := range handlers {
+		handler(event)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp, ok := s.responses[interaction.ID]
+	if !ok {
+		return nil, errors.New("interaction has not been acknowledged")
+	}
+	return resp, nil
+}
+
+// notify wakes up the goroutines that wait for messages, must be called while holding the lock.
+func (s *Session) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// message must be called while holding the lock.
+func (s *Session) message(channelID, messageID string) (int, bool) {
+	for idx, msg := range s.messages[channelID] {
+		if msg.ID == messageID {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// deleteMessage must be called while holding the lock.
+func (s *Session) deleteMessage(channelID, messageID string) error {
+	idx, ok := s.message(channelID, messageID)
+	if !ok {
+		return ErrUnknownMessage
+	}
+
+	messages := s.messages[channelID]
+	s.messages[channelID] = append(messages[:idx:idx], messages[idx+1:]...)
+	return nil
+}
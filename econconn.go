@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	econReconnectMinDelay = time.Second
+	econReconnectMaxDelay = 5 * time.Minute
+
+	econDialTimeout = 10 * time.Second
+	econKeepAlive   = 30 * time.Second
+)
+
+var (
+	// ErrNotConnected is returned when a line is written to or read from an econ connection that is currently down.
+	ErrNotConnected = errors.New("not connected to the external console")
+
+	// ErrInvalidPassword is returned when the external console rejects the password.
+	ErrInvalidPassword = errors.New("invalid econ password")
+
+	// commands that are executed on every connect and reconnect
+	econOnConnectCommands = []string{"ec_output_level 2"}
+)
+
+// econLink is a single authenticated connection to an external console.
+// It does not reconnect on its own, a broken link is replaced by the reader of the EconConn.
+type econLink struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialEconLink connects to the external console, authenticates and executes the on connect commands.
+func dialEconLink(addr Address, pass password) (*econLink, error) {
+	dialer := net.Dialer{
+		Timeout: econDialTimeout,
+		// detects connections that died without being closed, e.g. when the server's host went down
+		KeepAlive: econKeepAlive,
+	}
+	conn, err := dialer.Dial("tcp", string(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	link := &econLink{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	if err := link.authenticate(pass); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return link, nil
+}
+
+func (l *econLink) authenticate(pass password) error {
+	// the server must not be able to stall the bot during the handshake
+	l.conn.SetDeadline(time.Now().Add(econDialTimeout))
+	defer l.conn.SetDeadline(time.Time{})
+
+	line, err := l.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "Enter password:" {
+		return fmt.Errorf("unexpected password request: %s", line)
+	}
+
+	if err := l.writeLine(string(pass)); err != nil {
+		return err
+	}
+
+	line, err = l.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "Authentication successful. External console access granted." {
+		return fmt.Errorf("%w: %s", ErrInvalidPassword, line)
+	}
+
+	for _, cmd := range econOnConnectCommands {
+		if err := l.writeLine(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLine reads the next line, the server terminates every line with a line break and null bytes.
+func (l *econLink) readLine() (string, error) {
+	for {
+		line, err := l.reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		line = strings.Trim(line, "\x00\r\n")
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+func (l *econLink) writeLine(line string) error {
+	_, err := l.conn.Write([]byte(line + "\n"))
+	return err
+}
+
+// close logs out and closes the connection.
+func (l *econLink) close() error {
+	l.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	l.writeLine("logout")
+	return l.conn.Close()
+}
+
+// EconConn guards an econ connection that can be replaced at runtime.
+// This allows the command queue to keep working across reconnects.
+type EconConn struct {
+	mu   sync.Mutex
+	link *econLink
+
+	// serializes writes of different goroutines
+	writeMu sync.Mutex
+}
+
+func (c *EconConn) current() *econLink {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.link
+}
+
+// ReadLine reads a line from the current connection.
+// An error is returned as soon as the connection broke, the caller is responsible for reconnecting.
+func (c *EconConn) ReadLine() (string, error) {
+	link := c.current()
+	if link == nil {
+		return "", ErrNotConnected
+	}
+	return link.readLine()
+}
+
+// WriteLine writes a line to the current connection.
+// A connection that cannot be written to is closed, which lets the reader reconnect.
+func (c *EconConn) WriteLine(line string) error {
+	link := c.current()
+	if link == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	err := link.writeLine(line)
+	if err != nil {
+		link.conn.Close()
+	}
+	return err
+}
+
+// Reset replaces the current connection and closes the previous one.
+func (c *EconConn) Reset(link *econLink) {
+	c.mu.Lock()
+	previous := c.link
+	c.link = link
+	c.mu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// Close closes the current connection.
+func (c *EconConn) Close() {
+	c.Reset(nil)
+}
+
+// nextReconnectDelay doubles the passed delay up to the maximum reconnect delay.
+func nextReconnectDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > econReconnectMaxDelay {
+		return econReconnectMaxDelay
+	}
+	return delay
+}
+
+// dialEcon tries to connect to the external console until it either succeeds or the context is cancelled.
+// The delay between two attempts grows exponentially.
+func dialEcon(ctx context.Context, addr Address, pass password) (*econLink, error) {
+	delay := econReconnectMinDelay
+	for {
+		link, err := dialEconLink(addr, pass)
+		if err == nil {
+			return link, nil
+		}
+		log.Printf("failed to connect to %s, retrying in %s: %s", addr, delay, err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+			delay = nextReconnectDelay(delay)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package econtest provides a fake external console of a Teeworlds server for tests.
+// The server speaks the authentication handshake, records the executed commands
+// and can be scripted to emit log lines.
+package econtest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	passwordRequest       = "Enter password:"
+	authenticationSuccess = "Authentication successful. External console access granted."
+	authenticationFailure = "Wrong password"
+)
+
+var (
+	// ErrTimeout is returned when an awaited command or client did not arrive in time.
+	ErrTimeout = errors.New("timed out")
+)
+
+// Server is a fake external console that listens on a random local port.
+type Server struct {
+	listener net.Listener
+	password string
+
+	mu        sync.Mutex
+	changed   chan struct{}     // closed and replaced whenever commands or clients change
+	clients   map[net.Conn]bool // authenticated or not
+	commands  []string
+	responses map[string][]string
+	closed    bool
+
+	// lines of different goroutines must not be interleaved
+	writeMu sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts a fake external console that accepts the passed password.
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener:  listener,
+		password:  password,
+		changed:   make(chan struct{}),
+		clients:   make(map[net.Conn]bool),
+		commands:  make([]string, 0, 16),
+		responses: make(map[string][]string),
+	}
+
+	s.wg.Add(1)
+	go s.acceptRoutine()
+	return s, nil
+}
+
+// Addr returns the IP:Port address of the external console.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Handle scripts the response of a command, the lines are emitted whenever the command is executed.
+// The echo command is always answered like a real server does.
+func (s *Server) Handle(command string, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[command] = lines
+}
+
+// Emit sends log lines to all authenticated clients.
+func (s *Server) Emit(lines ...string) {
+	s.mu.Lock()
+	clients := make([]net.Conn, 0, len(s.clients))
+	for c, authenticated := range s.clients {
+		if authenticated {
+			clients = append(clients, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		s.writeLines(c, lines...)
+	}
+}
+
+// Commands returns all commands that were executed by the clients, oldest first.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]string, len(s.commands))
+	copy(result, s.commands)
+	return result
+}
+
+// WaitForCommand blocks until a command with the passed prefix was executed and returns it.
+// Commands that were executed before the call are considered as well.
+func (s *Server) WaitForCommand(prefix string, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		for _, cmd := range s.commands {
+			if strings.HasPrefix(cmd, prefix) {
+				s.mu.Unlock()
+				return cmd, nil
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return "", ErrTimeout
+		}
+	}
+}
+
+// WaitForClient blocks until at least one client is authenticated.
+func (s *Server) WaitForClient(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		connected := false
+		for _, authenticated := range s.clients {
+			connected = connected || authenticated
+		}
+		s.mu.Unlock()
+
+		if connected {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return ErrTimeout
+		}
+	}
+}
+
+// Disconnect closes the connections of all clients, which can reconnect afterwards.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		c.Close()
+	}
+}
+
+// Close stops the server and closes all connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.Disconnect()
+	s.wg.Wait()
+}
+
+// notify must be called while holding the lock.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) acceptRoutine() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.clients[conn] = false
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.clientRoutine(conn)
+	}
+}
+
+func (s *Server) clientRoutine(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.clients, conn)
+		s.notify()
+		s.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	writeLine(conn, passwordRequest)
+	line, err := readLine(reader)
+	if err != nil {
+		return
+	}
+
+	if line != s.password {
+		writeLine(conn, authenticationFailure)
+		return
+	}
+	writeLine(conn, authenticationSuccess)
+
+	s.mu.Lock()
+	s.clients[conn] = true
+	s.notify()
+	s.mu.Unlock()
+
+	for {
+		cmd, err := readLine(reader)
+		if err != nil {
+			return
+		}
+		if cmd == "logout" {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		response := s.responses[cmd]
+		s.notify()
+		s.mu.Unlock()
+
+		if strings.HasPrefix(cmd, "echo ") {
+			s.writeLines(conn, "[Console]: "+strings.TrimPrefix(cmd, "echo "))
+		}
+		s.writeLines(conn, response...)
+	}
+}
+
+// writeLines sends the lines to a client without interleaving them with lines of other goroutines.
+func (s *Server) writeLines(conn net.Conn, lines ...string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for _, line := range lines {
+		if err := writeLine(conn, line); err != nil {
+			return
+		}
+	}
+}
+
+// readLine reads a line that was sent by the client.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeLine sends a line the way the external console does, every line is followed by two null bytes.
+func writeLine(conn net.Conn, line string) error {
+	_, err := conn.Write([]byte(line + "\n\x00\x00"))
+	return err
+}
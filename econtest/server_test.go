@@ -0,0 +1,62 @@
+package econtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/twapi/econ"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_, err = econ.DialTo(s.Addr(), "wrong")
+	if !errors.Is(err, econ.ErrInvalidPassword) {
+		t.Fatalf("expected invalid password, got: %v", err)
+	}
+
+	s.Handle("status", "[Server]: id=0 addr=192.168.178.25:64139 name='nameless tee' score=0")
+
+	conn, err := econ.DialTo(s.Addr(), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := s.WaitForClient(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Emit("[chat]: 0:-2:nameless tee: hi")
+	if line, err := conn.ReadLine(); err != nil || line != "[chat]: 0:-2:nameless tee: hi" {
+		t.Fatalf("unexpected line %q: %v", line, err)
+	}
+
+	for _, cmd := range []string{"status", "echo done"} {
+		if err := conn.WriteLine(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"[Server]: id=0 addr=192.168.178.25:64139 name='nameless tee' score=0",
+		"[Console]: done",
+	}
+	for _, e := range expected {
+		if line, err := conn.ReadLine(); err != nil || line != e {
+			t.Fatalf("expected %q, got %q: %v", e, line, err)
+		}
+	}
+
+	if _, err := s.WaitForCommand("echo", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if cmds := s.Commands(); len(cmds) != 2 || cmds[0] != "status" {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+}
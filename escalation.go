@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// replaced with the escalated ban duration in minutes
+	minutesPlaceholder = "{MINUTES}"
+
+	// replaced with the reason of ?punish, the ban button uses the default ban reason
+	reasonPlaceholder = "{REASON}"
+	defaultBanReason  = "violation of rules"
+
+	// bans of the same player within this time window are a single offense, e.g. a multiban
+	offenseWindow = time.Minute
+)
+
+var (
+	// ErrEmptyBanEscalation is returned if no ban durations were passed.
+	ErrEmptyBanEscalation = errors.New("ban escalation needs at least one duration")
+
+	defaultBanEscalation = BanEscalation{
+		5 * time.Minute,
+		30 * time.Minute,
+		24 * time.Hour,
+		7 * 24 * time.Hour,
+	}
+)
+
+// BanEscalation is a ladder of ban durations, the n-th offense of a player is punished with the n-th duration.
+type BanEscalation []time.Duration
+
+// ParseBanEscalation parses a whitespace separated list of durations like 5m 30m 1d 7d
+func ParseBanEscalation(text string) (BanEscalation, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, ErrEmptyBanEscalation
+	}
+
+	escalation := make(BanEscalation, 0, len(fields))
+	for _, field := range fields {
+		duration, err := parseDuration(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if duration < time.Minute {
+			return nil, fmt.Errorf("ban duration must be at least one minute: %s", field)
+		}
+		escalation = append(escalation, duration)
+	}
+	return escalation, nil
+}
+
+// parseDuration extends time.ParseDuration with the day unit, e.g. 7d
+func parseDuration(text string) (time.Duration, error) {
+	if strings.HasSuffix(text, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(text, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", text)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(text)
+}
+
+// Next returns the ban duration of a player that has already committed the passed number of offenses.
+func (e BanEscalation) Next(offenses int) time.Duration {
+	if offenses >= len(e) {
+		return e[len(e)-1]
+	}
+	return e[offenses]
+}
+
+func (e BanEscalation) String() string {
+	durations := make([]string, 0, len(e))
+	for _, d := range e {
+		durations = append(durations, d.String())
+	}
+	return strings.Join(durations, " ")
+}
+
+// countOffenses counts the bans within the events, bans within the offense window are counted once.
+// Bans that enforce a global ban on a server are no new offenses.
+func countOffenses(events []BanEvent) int {
+	bans := make([]time.Time, 0, len(events))
+	for _, e := range events {
+		if e.Type == BanEventBan && e.Author != globalBanAuthor {
+			bans = append(bans, e.Time)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Before(bans[j])
+	})
+
+	offenses := 0
+	last := time.Time{}
+	for _, banTime := range bans {
+		if offenses == 0 || banTime.Sub(last) > offenseWindow {
+			offenses++
+			last = banTime
+		}
+	}
+	return offenses
+}
+
+// previousOffenses counts the previous bans of the player's IP, its nickname
+// and all IPs the nickname has been seen with.
+func (b *Bot) previousOffenses(p Player) int {
+	ips := map[string]bool{}
+	if p.IP != "" {
+		ips[p.IP] = true
+	}
+
+	knownName := p.Name != "" && p.Name != "(unknown)"
+	if knownName {
+		knownIPs, _ := b.NicknameTracker.IPs(p.Name)
+		for _, ip := range knownIPs {
+			ips[ip] = true
+		}
+	}
+
+	events := make([]BanEvent, 0, 4)
+	for ip := range ips {
+		ipEvents, _ := b.BanHistory.ByIP(ip)
+		events = append(events, ipEvents...)
+	}
+
+	if knownName {
+		nameEvents, _ := b.BanHistory.ByName(p.Name)
+		events = append(events, nameEvents...)
+	}
+
+	return countOffenses(events)
+}
+
+// escalatedBanMinutes returns the number of minutes that the player is to be banned next.
+func (b *Bot) escalatedBanMinutes(p Player) (minutes, offenses int) {
+	offenses = b.previousOffenses(p)
+	return int(b.BanEscalation.Next(offenses).Minutes()), offenses
+}
+
+// withReason replaces the reason placeholder of a ban command, templates without it keep their own reason.
+func withReason(cmd, reason string) string {
+	return strings.ReplaceAll(cmd, reasonPlaceholder, reason)
+}
+
+// withEscalatedDuration replaces the minutes placeholder of a ban command with the escalated ban duration.
+func (b *Bot) withEscalatedDuration(cmd string, p Player) string {
+	if !strings.Contains(cmd, minutesPlaceholder) {
+		return cmd
+	}
+
+	minutes, _ := b.escalatedBanMinutes(p)
+	return strings.ReplaceAll(cmd, minutesPlaceholder, strconv.Itoa(minutes))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBanEscalation(t *testing.T) {
+	escalation, err := ParseBanEscalation("5m 30m 1d 7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offenses int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 30 * time.Minute},
+		{2, 24 * time.Hour},
+		{3, 7 * 24 * time.Hour},
+		{10, 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := escalation.Next(tt.offenses); got != tt.want {
+			t.Errorf("Next(%d) = %s, want %s", tt.offenses, got, tt.want)
+		}
+	}
+
+	if _, err := ParseBanEscalation(""); err == nil {
+		t.Error("empty escalation should be invalid")
+	}
+
+	if _, err := ParseBanEscalation("5m 30s"); err == nil {
+		t.Error("durations below one minute should be invalid")
+	}
+}
+
+func Test_countOffenses(t *testing.T) {
+	now := time.Now()
+
+	events := []BanEvent{
+		// multiban on three servers
+		{Type: BanEventBan, Time: now.Add(-time.Hour)},
+		{Type: BanEventBan, Time: now.Add(-time.Hour + time.Second)},
+		{Type: BanEventBan, Time: now.Add(-time.Hour + 2*time.Second)},
+		{Type: BanEventExpire, Time: now.Add(-30 * time.Minute)},
+		{Type: BanEventBan, Time: now},
+		// same event found by nickname
+		{Type: BanEventBan, Time: now},
+		// global ban that was enforced after a reconnect
+		{Type: BanEventBan, Time: now.Add(time.Hour), Author: globalBanAuthor},
+	}
+
+	if got := countOffenses(events); got != 2 {
+		t.Fatalf("Expected 2 offenses, got %d", got)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Event is a structured event that is parsed from a log line of a server.
+// Parsing and rendering are separate steps, which allows other features to handle
+// the events without parsing the messages that are sent to Discord.
+type Event interface {
+	// Render formats the event as a Discord message, empty messages are not sent.
+	// Whether an event is shown at all depends on the log level of the bot.
+	Render() string
+}
+
+// EventCallback is a function that takes an event as parameter.
+type EventCallback func(Event)
+
+// JoinEvent is emitted when a player joins the server.
+type JoinEvent struct {
+	Player Player
+
+	// Discord users that requested to be notified when the player joins
+	Mentions []string
+}
+
+// Render formats the join and mentions the users that want to be notified.
+func (e JoinEvent) Render() string {
+	if len(e.Mentions) > 0 {
+		return fmt.Sprintf("[server]: '%s' joined the server with id %d\n%s", Escape(e.Player.Name), e.Player.ID, strings.Join(e.Mentions, " "))
+	}
+	return fmt.Sprintf("[server]: '%s' joined the server with id %d", Escape(e.Player.Name), e.Player.ID)
+}
+
+// LeaveEvent is emitted when a player leaves the server.
+type LeaveEvent struct {
+	Player Player
+	Reason string
+}
+
+// Render formats the leave.
+func (e LeaveEvent) Render() string {
+	return fmt.Sprintf("[server]: '%s' left the server, id was %d", Escape(e.Player.Name), e.Player.ID)
+}
+
+// ServerEvent is a server message that is not parsed any further.
+type ServerEvent struct {
+	Line string
+}
+
+// Render formats the server message.
+func (e ServerEvent) Render() string {
+	return fmt.Sprintf("[server]: %s", Escape(e.Line))
+}
+
+// Render formats the ban, unban or expiry.
+func (e BanEvent) Render() string {
+	name := Escape(e.Player.Name)
+
+	var line string
+	switch e.Type {
+	case BanEventBan:
+		return fmt.Sprintf("**[bans]**: '%s' banned for %9s with reason: '%s'", name, formatBanDuration(e.Duration), e.Reason)
+	case BanEventUnban:
+		line = fmt.Sprintf("[bans]: unbanned '%s'", name)
+	case BanEventExpire:
+		line = fmt.Sprintf("[bans]: ban of '%s' expired", name)
+	default:
+		return ""
+	}
+
+	// the reason of unknown bans is not known
+	if e.Reason != "" {
+		line += fmt.Sprintf(" (%s)", e.Reason)
+	}
+	return line
+}
+
+// UnbanAllEvent is emitted when all bans of the server were removed.
+type UnbanAllEvent struct{}
+
+// Render formats the removal of all bans.
+func (e UnbanAllEvent) Render() string {
+	return "[bans]: unbanned all players."
+}
+
+// ErrorEvent is an error message of the server.
+type ErrorEvent struct {
+	Message string
+}
+
+// Render formats the error.
+func (e ErrorEvent) Render() string {
+	return fmt.Sprintf("**[error]**: %s", e.Message)
+}
+
+// VoteType is the kind of a started vote.
+type VoteType string
+
+// possible vote types
+const (
+	VoteKick     VoteType = "kick"
+	VoteSpectate VoteType = "spectate"
+	VoteOption   VoteType = "option"
+)
+
+// VoteStartEvent is emitted when a player starts a vote.
+type VoteStartEvent struct {
+	ID    int // of the server's vote tracking
+	Type  VoteType
+	Voter Player
+
+	// voted player of kick and spectate votes
+	Target Player
+
+	// voted option of option votes
+	Option string
+
+	Reason  string
+	Command string
+	Forced  bool
+}
+
+// Render formats the started vote.
+func (e VoteStartEvent) Render() string {
+	forced := ""
+	if e.Forced {
+		forced = "/forced"
+	}
+
+	switch e.Type {
+	case VoteKick:
+		return fmt.Sprintf("**[kickvote%s]**: %d:'%s' started to kick %d:'%s' with reason '%s'", forced, e.Voter.ID, Escape(e.Voter.Name), e.Target.ID, Escape(e.Target.Name), Escape(e.Reason))
+	case VoteSpectate:
+		return fmt.Sprintf("**[specvote%s]**: %d:'%s' wants to move %d:'%s' to spectators with reason '%s'", forced, e.Voter.ID, Escape(e.Voter.Name), e.Target.ID, Escape(e.Target.Name), Escape(e.Reason))
+	case VoteOption:
+		return fmt.Sprintf("**[optionvote%s]**: %d:'%s' voted option '%s' with reason '%s'", forced, e.Voter.ID, Escape(e.Voter.Name), Escape(e.Option), Escape(e.Reason))
+	}
+	return ""
+}
+
+// VoteForcedEvent is emitted when an admin forces the result of the current vote.
+type VoteForcedEvent struct {
+	Yes    bool
+	Author string // Discord user that forced the vote, empty if it was forced ingame
+}
+
+// Render formats the forced vote result.
+func (e VoteForcedEvent) Render() string {
+	line := "**[server]**: Forced No"
+	if e.Yes {
+		line = "**[server]**: Forced Yes"
+	}
+
+	if e.Author != "" {
+		line += " by " + Escape(e.Author)
+	}
+	return line
+}
+
+// VoteEndEvent is emitted when the server reports the outcome of the current vote.
+type VoteEndEvent struct {
+	Vote Vote
+}
+
+// Render formats the outcome of the vote.
+func (e VoteEndEvent) Render() string {
+	return e.Vote.Render()
+}
+
+// RconAuthEvent is emitted when a player logs into the remote console.
+type RconAuthEvent struct {
+	Player Player
+	Rank   string
+}
+
+// Render formats the login.
+func (e RconAuthEvent) Render() string {
+	return fmt.Sprintf("**[rcon]**: '%s' authed as **%s**", Escape(e.Player.Name), e.Rank)
+}
+
+// RconCommandEvent is emitted when a player executes a remote console command.
+type RconCommandEvent struct {
+	Player  Player
+	Command string
+}
+
+// Render formats the executed command.
+func (e RconCommandEvent) Render() string {
+	return fmt.Sprintf("**[rcon]**: '%s' command='%s'", Escape(e.Player.Name), Escape(e.Command))
+}
+
+// ChatType is the kind of a chat message.
+type ChatType string
+
+// possible chat types
+const (
+	ChatAll     ChatType = "chat"
+	ChatTeam    ChatType = "teamchat"
+	ChatWhisper ChatType = "whisper"
+)
+
+// ChatEvent is a chat message of a player, the ID is negative for messages of the server.
+type ChatEvent struct {
+	Type ChatType
+	ID   int
+	Name string
+	Text string
+}
+
+// Render formats the chat message.
+func (e ChatEvent) Render() string {
+	return e.render(Escape(e.Text))
+}
+
+// render formats the chat message with its already escaped text.
+func (e ChatEvent) render(text string) string {
+	switch e.Type {
+	case ChatAll, ChatTeam:
+		return fmt.Sprintf("[%s]: %d:'%s': %s", e.Type, e.ID, Escape(e.Name), text)
+	case ChatWhisper:
+		return fmt.Sprintf("[whisper] %d:'%s': %s", e.ID, Escape(e.Name), text)
+	}
+	return ""
+}
+
+// shown returns false for events that are hidden by the log level of the bot.
+// Joins are shown with a log level of 2 or if someone wants to be notified, leaves
+// with a log level of 2 and whispers with a log level of 1 or if the player is spied on.
+// Vote outcomes are never shown on their own.
+func (b *Bot) shown(event Event) bool {
+	switch e := event.(type) {
+	case JoinEvent:
+		return len(e.Mentions) > 0 || b.LogLevel >= 2
+	case LeaveEvent:
+		return b.LogLevel >= 2
+	case ChatEvent:
+		return e.Type != ChatWhisper || b.LogLevel >= 1 || b.SpiedOnPlayers.Contains(e.Name)
+	case VoteEndEvent:
+		// the outcome is added to the message of the vote
+		return false
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// bans are listed with a precision of minutes, smaller differences are not re-applied.
+	globalBanTolerance = 2 * time.Minute
+
+	// the author of commands that enforce global bans
+	globalBanAuthor = "global ban list"
+)
+
+// GlobalBan is a ban that is enforced on every moderated server.
+type GlobalBan struct {
+	IP        string    `json:"ip"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Reason    string    `json:"reason"`
+	Author    string    `json:"author"`
+}
+
+// NewGlobalBan creates a global ban, a non positive duration bans the player permanently.
+func NewGlobalBan(p Player, duration time.Duration, reason, author string) GlobalBan {
+	ban := GlobalBan{
+		IP:     p.IP,
+		Name:   p.Name,
+		Reason: reason,
+		Author: author,
+	}
+	if duration > 0 {
+		ban.ExpiresAt = time.Now().Add(duration)
+	}
+	return ban
+}
+
+// Permanent returns true if the ban never expires.
+func (b *GlobalBan) Permanent() bool {
+	return b.ExpiresAt.IsZero()
+}
+
+// Expired tests if the ban is already expired.
+func (b *GlobalBan) Expired() bool {
+	return !b.Permanent() && time.Now().After(b.ExpiresAt)
+}
+
+// Command returns the econ command that bans the IP for the remaining ban time.
+func (b *GlobalBan) Command() string {
+	return banCommand(b.IP, remainingMinutes(b.ExpiresAt), b.Reason)
+}
+
+// differsFrom returns true if the ban of a server does not match the global ban.
+func (b *GlobalBan) differsFrom(ban Ban) bool {
+	if b.Permanent() || ban.Permanent() {
+		return b.Permanent() != ban.Permanent()
+	}
+
+	diff := b.ExpiresAt.Sub(ban.ExpiresAt)
+	return diff > globalBanTolerance || diff < -globalBanTolerance
+}
+
+// GlobalBanList is the source of truth of all bans that are shared by the moderated servers.
+// Servers are reconciled with it whenever their ban list is received.
+type GlobalBanList struct {
+	mu   sync.Mutex
+	path string
+	bans map[string]GlobalBan
+}
+
+// NewGlobalBanList loads the global bans from the passed file, a missing file is not an error.
+// An empty path keeps the global bans in memory only.
+func NewGlobalBanList(path string) (*GlobalBanList, error) {
+	l := &GlobalBanList{
+		path: path,
+		bans: make(map[string]GlobalBan),
+	}
+
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var bans []GlobalBan
+	err = json.Unmarshal(data, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ban := range bans {
+		if !ban.Expired() {
+			l.bans[ban.IP] = ban
+		}
+	}
+	return l, nil
+}
+
+// save must be called while holding the lock.
+func (l *GlobalBanList) save() {
+	if l.path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(l.sorted(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(l.path, data)
+	}
+
+	if err != nil {
+		log.Printf("error while saving the global bans to %s: %s", l.path, err.Error())
+	}
+}
+
+// sorted must be called while holding the lock, expired bans are removed.
+func (l *GlobalBanList) sorted() []GlobalBan {
+	result := make([]GlobalBan, 0, len(l.bans))
+	for ip, ban := range l.bans {
+		if ban.Expired() {
+			delete(l.bans, ip)
+			continue
+		}
+		result = append(result, ban)
+	}
+
+	// same order as the server's ban list, permanent bans last
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Permanent() != b.Permanent() {
+			return b.Permanent()
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return a.IP < b.IP
+	})
+	return result
+}
+
+// Add adds or replaces the global ban of an IP.
+func (l *GlobalBanList) Add(ban GlobalBan) {
+	if ban.IP == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bans[ban.IP] = ban
+	l.save()
+}
+
+// Remove removes the global ban of an IP, the bans on the servers are not affected.
+func (l *GlobalBanList) Remove(ip string) (GlobalBan, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ban, ok := l.bans[ip]
+	if !ok {
+		return GlobalBan{}, false
+	}
+
+	delete(l.bans, ip)
+	l.save()
+	return ban, !ban.Expired()
+}
+
+// Get returns the global ban of an IP.
+func (l *GlobalBanList) Get(ip string) (GlobalBan, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ban, ok := l.bans[ip]
+	if !ok || ban.Expired() {
+		return GlobalBan{}, false
+	}
+	return ban, true
+}
+
+// Bans returns all global bans that did not expire yet.
+func (l *GlobalBanList) Bans() []GlobalBan {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.sorted()
+}
+
+// Diff returns the global bans that are either missing in the passed ban list of a server
+// or whose remaining ban time differs.
+func (l *GlobalBanList) Diff(serverBans []Ban) []GlobalBan {
+	byIP := make(map[string]Ban, len(serverBans))
+	for _, ban := range serverBans {
+		byIP[ban.Player.IP] = ban
+	}
+
+	result := make([]GlobalBan, 0, 4)
+	for _, ban := range l.Bans() {
+		serverBan, ok := byIP[ban.IP]
+		if !ok || ban.differsFrom(serverBan) {
+			result = append(result, ban)
+		}
+	}
+	return result
+}
+
+// String formats the global ban list.
+func (l *GlobalBanList) String() string {
+	bans := l.Bans()
+
+	sb := strings.Builder{}
+	for idx, ban := range bans {
+		banTimeLeft := "permanent"
+		if !ban.Permanent() {
+			banTimeLeft = time.Until(ban.ExpiresAt).Round(time.Second).String()
+		}
+		sb.WriteString(fmt.Sprintf("idx=%-2d %9s '%s' (%s) by %s\n", idx, banTimeLeft, ban.Name, ban.Reason, ban.Author))
+	}
+	return sb.String()
+}
+
+// banOnAllServers adds the ban to the global ban list and bans the IP or IP range on all moderated servers.
+func (b *Bot) banOnAllServers(ban GlobalBan) {
+	b.GlobalBans.Add(ban)
+
+	// offline servers apply the ban as soon as they reconnect
+	b.queueCommandOnAllServers(command{
+		Author:  ban.Author,
+		Command: ban.Command(),
+	})
+}
+
+// enforceGlobalBans bans all IPs of the global ban list on the server, which are either missing
+// in the server's ban list or whose remaining ban time differs.
+func (b *Bot) enforceGlobalBans(addr Address, serverBans []Ban) {
+	if b.GlobalBans == nil {
+		return
+	}
+
+	for _, ban := range b.GlobalBans.Diff(serverBans) {
+		cmd := command{
+			Author:  globalBanAuthor,
+			Command: ban.Command(),
+		}
+
+		if !b.queueCommand(addr, cmd) {
+			log.Printf("stopped enforcing the global bans on %s", addr)
+			return
+		}
+	}
+}
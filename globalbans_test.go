@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGlobalBanList_Diff(t *testing.T) {
+	l, _ := NewGlobalBanList("")
+
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.1", Name: "missing"}, time.Hour, "spam", "mod"))
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.2", Name: "shorter"}, time.Hour, "spam", "mod"))
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.3", Name: "same"}, time.Hour, "spam", "mod"))
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.4", Name: "permanent"}, 0, "spam", "mod"))
+
+	serverBans := []Ban{
+		{Player: Player{IP: "127.0.0.2"}, ExpiresAt: time.Now().Add(10 * time.Minute)},
+		{Player: Player{IP: "127.0.0.3"}, ExpiresAt: time.Now().Add(59 * time.Minute)},
+		{Player: Player{IP: "127.0.0.4"}},
+	}
+
+	diff := l.Diff(serverBans)
+	if len(diff) != 2 || diff[0].Name != "missing" || diff[1].Name != "shorter" {
+		t.Fatalf("Expected the missing and the shorter ban, got %v", diff)
+	}
+
+	if cmd := diff[0].Command(); cmd != "ban 127.0.0.1 60 spam" {
+		t.Errorf("Unexpected command: %s", cmd)
+	}
+
+	if ban, _ := l.Get("127.0.0.4"); ban.Command() != "ban 127.0.0.4 0 spam" {
+		t.Errorf("Unexpected command of a permanent ban: %s", ban.Command())
+	}
+}
+
+func TestGlobalBanList_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globalbans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "globalbans.json")
+
+	l, err := NewGlobalBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.1"}, time.Hour, "spam", "mod"))
+	l.Add(NewGlobalBan(Player{IP: "127.0.0.2"}, time.Hour, "spam", "mod"))
+	l.Remove("127.0.0.2")
+
+	l, err = NewGlobalBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bans := l.Bans()
+	if len(bans) != 1 || bans[0].IP != "127.0.0.1" {
+		t.Fatalf("Expected a single global ban, got %v", bans)
+	}
+}
+
+func TestBot_EnforceGlobalBans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globalbans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := NewBanHistory(filepath.Join(dir, "bans.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(Options{
+		DiscordToken:  "token",
+		DiscordAdmin:  testAdmin.String(),
+		Servers:       []ServerOptions{{Address: testAddress, Password: "pw"}},
+		BanEscalation: defaultBanEscalation,
+		BanHistory:    history,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	queue := make(chan command, 16)
+	b.DiscordCommandQueue[testAddress] = queue
+	b.ChannelAddress.Set(ChannelBinding{ChannelID: testChannelID, Address: testAddress})
+
+	server := b.ServerStates[testAddress]
+	player := Player{Name: "(unknown)", IP: "192.168.178.31"}
+
+	// executes a queued command the same way the command queue routine does
+	execute := func(cmd command, line string) {
+		server.SetCommandAuthor(cmd.Author)
+		zcatchProfile.ParseLine("net_ban", line, server)
+	}
+
+	// permanently banned an hour ago
+	b.GlobalBans.Add(NewGlobalBan(player, 0, "cheats", testModerator.String()))
+	err = history.Add(BanEvent{
+		Time:   time.Now().Add(-time.Hour),
+		Type:   BanEventBan,
+		Server: testAddress,
+		Player: player,
+		Reason: "cheats",
+		Author: testModerator.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minutes, offenses := b.escalatedBanMinutes(player)
+	if offenses != 1 {
+		t.Fatalf("Expected 1 offense, got %d", offenses)
+	}
+
+	// response of the bans command, the ban is already enforced
+	zcatchProfile.ParseLine("net_ban", "#0 '192.168.178.31' banned for life (cheats)", server)
+	zcatchProfile.ParseLine("net_ban", "1 ban", server)
+	if diff := b.GlobalBans.Diff(server.BanServer.Bans()); len(diff) != 0 {
+		t.Fatalf("Expected the global ban to match the ban list, got %v", diff)
+	}
+
+	// the server lost its bans during a restart
+	zcatchProfile.ParseLine("net_ban", "0 bans", server)
+
+	select {
+	case cmd := <-queue:
+		if cmd.Author != globalBanAuthor || cmd.Command != "ban 192.168.178.31 0 cheats" {
+			t.Fatalf("unexpected command: %#v", cmd)
+		}
+		execute(cmd, "banned '192.168.178.31' for life (cheats)")
+	case <-time.After(time.Second):
+		t.Fatal("global ban was not enforced")
+	}
+
+	if got, gotOffenses := b.escalatedBanMinutes(player); got != minutes || gotOffenses != offenses {
+		t.Fatalf("enforcing a global ban escalated the ban from %d to %d minutes", minutes, got)
+	}
+}
@@ -1,13 +1,22 @@
 module github.com/jxsl13/TeeworldsEconDiscordModerationBot
 
-go 1.13
+go 1.18
 
 require (
-	github.com/bwmarrin/discordgo v0.20.2
+	github.com/bwmarrin/discordgo v0.29.0
 	github.com/go-redis/redis v6.15.7+incompatible
 	github.com/joho/godotenv v1.3.0
 	github.com/jxsl13/twapi v1.2.1
+	go.etcd.io/bbolt v1.3.7
+)
+
+require (
+	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/onsi/ginkgo v1.15.2 // indirect
 	github.com/onsi/gomega v1.11.0 // indirect
+	github.com/reiver/go-oi v1.0.0 // indirect
+	github.com/reiver/go-telnet v0.0.0-20180421082511-9ff0b2ab096e // indirect
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
 	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 )
@@ -3,130 +3,245 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// CommandContext contains everything that a command handler needs in order to execute a command.
+// Handlers only talk to Discord via the session, which allows to test them without a network.
+type CommandContext struct {
+	Session DiscordSession
+	Bot     *Bot
+	Message *discordgo.MessageCreate
+
+	Author  string
+	Command string
+	Args    string
+}
+
+// Reply sends a message to the channel that the command was sent in.
+func (c *CommandContext) Reply(content string) (*discordgo.Message, error) {
+	return c.Session.ChannelMessageSend(c.Message.ChannelID, content)
+}
+
+// ReplySplit sends a long message to the channel that the command was sent in, split into multiple messages.
+func (c *CommandContext) ReplySplit(content string) {
+	SplitChannelMessageSend(c.Session, c.Message.ChannelID, content)
+}
+
 // MessageCommandHandler is a function that handles a newly created user message
-type MessageCommandHandler func(*discordgo.Session, *discordgo.MessageCreate, string, string, string)
+type MessageCommandHandler func(*CommandContext)
 
 // MessageCommandMiddleware is a wrapper fucntion
 type MessageCommandMiddleware func(MessageCommandHandler) MessageCommandHandler
 
 // AdminMessageCreateMiddleware is a wrapper that wraps around specific handler functions in order to deny access to non-admin users.
 func AdminMessageCreateMiddleware(next MessageCommandHandler) MessageCommandHandler {
-	return func(s *discordgo.Session, m *discordgo.MessageCreate, author, command, args string) {
+	return func(c *CommandContext) {
 
-		if config.DiscordAdmin == "" || m.Author.String() != config.DiscordAdmin {
-			s.ChannelMessageSend(m.ChannelID, "you are not allowed to access this command.")
+		if c.Bot.DiscordAdmin == "" || c.Message.Author.String() != c.Bot.DiscordAdmin {
+			c.Reply("you are not allowed to access this command.")
 			return
 		}
-		next(s, m, author, command, args)
+		next(c)
 	}
 }
 
 // ModeratorCommandsHandler handles all moderator commands
-func ModeratorCommandsHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, cmd, args string) {
-	addr, ok := config.GetAddressByChannelID(m.ChannelID)
+func ModeratorCommandsHandler(c *CommandContext) {
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
 	if !ok {
-		log.Printf("Request from invalid channel by user %s", author)
+		log.Printf("Request from invalid channel by user %s", c.Author)
 		return
 	}
 
-	if cmd == "" {
+	if c.Command == "" {
 		return
 	}
 
 	// check if moderator has access to these commands
-	if !config.DiscordModeratorCommands.Contains(cmd) {
-		s.ChannelMessageSend(m.ChannelID, "invalid command: "+cmd)
+	if !c.Bot.DiscordModeratorCommands.Contains(c.Command) {
+		c.Reply("invalid command: " + c.Command)
 		return
 	}
 
-	switch cmd {
+	switch c.Command {
 	case "help":
-		HelpHandler(s, m, author, args)
+		HelpHandler(c)
 	case "status":
-		StatusHandler(s, m, author, args)
+		StatusHandler(c)
 	case "bans":
-		BansHandler(s, m, author, args)
+		BansHandler(c)
 	case "multiban":
-		MultiBanHandler(s, m, author, args)
+		MultiBanHandler(c)
 	case "multiunban":
-		MultiUnbanHandler(s, m, author, args)
+		MultiUnbanHandler(c)
 	case "notify":
-		NotifyHandler(s, m, author, args)
+		NotifyHandler(c)
 	case "unnotify":
-		UnnotifyHandler(s, m, author, args)
+		UnnotifyHandler(c)
 	case "whois":
-		WhoisHandler(s, m, author, args)
+		WhoisHandler(c)
+	case "banhistory":
+		BanHistoryHandler(c)
+	case "punish":
+		PunishHandler(c)
+	case "globalbans":
+		GlobalBansHandler(c)
+	case "globalban":
+		GlobalBanHandler(c)
+	case "localban":
+		LocalBanHandler(c)
 	default:
 
 		// other command sprefixed with ? and that moderators
 		//have access to are directly passed to the external console
-		config.DiscordCommandQueue[addr] <- command{Author: author, Command: fmt.Sprintf("%s %s", cmd, args)}
+		c.Bot.queueCommand(addr, command{Author: c.Author, Command: fmt.Sprintf("%s %s", c.Command, c.Args)})
 	}
 }
 
 // AdminCommandsHandler handles the commands of the admin.
-func AdminCommandsHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, cmd, args string) {
+func AdminCommandsHandler(c *CommandContext) {
 
-	addr, ok := config.GetAddressByChannelID(m.ChannelID)
-	if !ok && cmd != "moderate" {
-		log.Printf("Request from invalid channel by user %s", author)
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+	if !ok && c.Command != "moderate" && c.Command != "rebind" && c.Command != "unmoderate" {
+		log.Printf("Request from invalid channel by user %s", c.Author)
 		return
 	}
 
-	if cmd == "" {
+	if c.Command == "" {
 		return
 	}
 
-	switch cmd {
+	switch c.Command {
 	case "help":
-		HelpHandler(s, m, author, args)
+		HelpHandler(c)
 	case "status":
-		StatusHandler(s, m, author, args)
+		StatusHandler(c)
 	case "bans":
-		BansHandler(s, m, author, args)
+		BansHandler(c)
 	case "multiban":
-		MultiBanHandler(s, m, author, args)
+		MultiBanHandler(c)
 	case "multiunban":
-		MultiUnbanHandler(s, m, author, args)
+		MultiUnbanHandler(c)
 	case "notify":
-		NotifyHandler(s, m, author, args)
+		NotifyHandler(c)
 	case "unnotify":
-		UnnotifyHandler(s, m, author, args)
+		UnnotifyHandler(c)
 	case "whois":
-		WhoisHandler(s, m, author, args)
+		WhoisHandler(c)
+	case "banhistory":
+		BanHistoryHandler(c)
+	case "punish":
+		PunishHandler(c)
+	case "globalbans":
+		GlobalBansHandler(c)
+	case "globalban":
+		GlobalBanHandler(c)
+	case "localban":
+		LocalBanHandler(c)
 	case "ips":
-		IPsHandler(s, m, author, args)
+		IPsHandler(c)
 	case "announce":
-		AnnounceHandler(s, m, author, args)
+		AnnounceHandler(c)
 	case "unannounce":
-		UnannounceHandler(s, m, author, args)
+		UnannounceHandler(c)
 	case "announcements":
-		AnnouncementsHandler(s, m, author, args)
+		AnnouncementsHandler(c)
 	case "add":
-		AddHandler(s, m, author, args)
+		AddHandler(c)
 	case "remove":
-		RemoveHandler(s, m, author, args)
+		RemoveHandler(c)
 	case "purge":
-		PurgeHandler(s, m, author, args)
+		PurgeHandler(c)
 	case "clean":
-		CleanHandler(s, m, author, args)
+		CleanHandler(c)
 	case "moderate":
-		ModerateHandler(s, m, author, args)
+		ModerateHandler(c)
+	case "unmoderate":
+		UnmoderateHandler(c)
+	case "rebind":
+		RebindHandler(c)
 	case "spy":
-		SpyHandler(s, m, author, args)
+		SpyHandler(c)
 	case "unspy":
-		UnspyHandler(s, m, author, args)
+		UnspyHandler(c)
 	case "purgespy":
-		PurgeSpyHandler(s, m, author, args)
+		PurgeSpyHandler(c)
 	case "execute":
-		ExecuteHandler(s, m, author, args)
+		ExecuteHandler(c)
 	case "bulkmultiban":
-		BulkMultibanHandler(s, m, author, args)
+		BulkMultibanHandler(c)
+	case "confirmban":
+		ConfirmBanHandler(c)
+	case "exportbans":
+		ExportBansHandler(c)
+	case "importbans":
+		ImportBansHandler(c)
 	default:
-		config.DiscordCommandQueue[addr] <- command{Author: author, Command: fmt.Sprintf("%s %s", cmd, args)}
+		c.Bot.queueCommand(addr, command{Author: c.Author, Command: fmt.Sprintf("%s %s", c.Command, c.Args)})
+	}
+}
+
+// CommandMessageHandler executes the moderator and admin commands of a message, each line may contain a command.
+func (b *Bot) CommandMessageHandler(s DiscordSession, m *discordgo.MessageCreate) {
+	// author stays the same
+	author := m.Author.String()
+
+	// each new line might contain a command
+	lines := strings.Split(m.Content, "\n")
+
+	// try to execute each command
+	for _, line := range lines {
+
+		prefix := ""
+		command := ""
+		args := ""
+
+		if len(line) >= 1 {
+			prefix = line[:1]
+		} else {
+			continue
+		}
+
+		if len(line) >= 2 {
+			strs := strings.SplitN(line[1:], " ", 2)
+
+			if len(strs) >= 1 {
+				command = strs[0]
+			}
+
+			if len(strs) == 2 {
+				args = strs[1]
+			}
+		}
+
+		c := &CommandContext{
+			Session: s,
+			Bot:     b,
+			Message: m,
+			Author:  author,
+			Command: command,
+			Args:    args,
+		}
+
+		switch prefix {
+		case "?":
+			if !b.DiscordModerators.Contains(author) {
+				c.Reply("no access to moderator commands.")
+				continue
+			}
+			ModeratorCommandsHandler(c)
+		case "#":
+			if author != b.DiscordAdmin {
+				c.Reply("no access to admin commands.")
+				continue
+			}
+			AdminCommandsHandler(c)
+		default:
+			continue
+		}
+
 	}
 }
@@ -3,34 +3,33 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
-	"net"
+	"net/http"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 // IPsHandler allows to check a specific player's knonw IPs. This is helpful if players try to rejoin the server
 // after being banned or in any way punished for some reason. These players can then be banned by all their known IPs.
-func IPsHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	nickname := strings.TrimSpace(args)
-	if config.NicknameTracker == nil {
-		s.ChannelMessageSend(m.ChannelID, "nickname tracking is disabled.")
+func IPsHandler(c *CommandContext) {
+	nickname := strings.TrimSpace(c.Args)
+	if c.Bot.NicknameTracker == nil {
+		c.Reply("nickname tracking is disabled.")
 		return
 	}
 
-	ips, err := config.NicknameTracker.IPs(nickname)
+	ips, err := c.Bot.NicknameTracker.IPs(nickname)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
+		c.Reply(err.Error())
 		return
 	}
 
 	if len(ips) == 0 {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("**Unknown nickname** `%s`, did not find any IPs.", nickname))
+		c.Reply(fmt.Sprintf("**Unknown nickname** `%s`, did not find any IPs.", nickname))
 		return
 	}
 
@@ -42,84 +41,84 @@ func IPsHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args s
 	}
 	sb.WriteString("```\n")
 
-	SplitChannelMessageSend(s, m, sb.String())
+	c.ReplySplit(sb.String())
 }
 
 // AnnounceHandler allows to add a server specific announcement.
-func AnnounceHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	as, ok := config.GetAnnouncementServerByChannelID(m.ChannelID)
+func AnnounceHandler(c *CommandContext) {
+	as, ok := c.Bot.GetAnnouncementServerByChannelID(c.Message.ChannelID)
 	if !ok {
 		return
 	}
 
-	err := as.AddAnnouncement(args)
+	err := as.AddAnnouncement(c.Args)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
+		c.Reply(err.Error())
 		return
 	}
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("registered announcement: %s", args))
+	c.Reply(fmt.Sprintf("registered announcement: %s", c.Args))
 }
 
 // UnannounceHandler allows to remove an announcement by its id.
-func UnannounceHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	index, err := strconv.Atoi(args)
+func UnannounceHandler(c *CommandContext) {
+	index, err := strconv.Atoi(c.Args)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "invalid id argument")
+		c.Reply("invalid id argument")
 		return
 	}
 
-	as, ok := config.GetAnnouncementServerByChannelID(m.ChannelID)
+	as, ok := c.Bot.GetAnnouncementServerByChannelID(c.Message.ChannelID)
 	if !ok {
-		s.ChannelMessageSend(m.ChannelID, "invalid channel id")
+		c.Reply("invalid channel id")
 		return
 	}
 
 	ann, err := as.Delete(index)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
+		c.Reply(err.Error())
 		return
 	}
 
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed: %s %s", ann.Delay.String(), ann.Message))
+	c.Reply(fmt.Sprintf("Removed: %s %s", ann.Delay.String(), ann.Message))
 }
 
 // AnnouncementsHandler shows a list of registered announcements with their delay and corresponding id.
-func AnnouncementsHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	as, ok := config.GetAnnouncementServerByChannelID(m.ChannelID)
+func AnnouncementsHandler(c *CommandContext) {
+	as, ok := c.Bot.GetAnnouncementServerByChannelID(c.Message.ChannelID)
 	if !ok {
 		return
 	}
 
-	SplitChannelMessageSend(s, m, fmt.Sprintf("Announcements:\n%s", as.String()))
+	c.ReplySplit(fmt.Sprintf("Announcements:\n%s", as.String()))
 }
 
 // AddHandler adds a moderator to the moderators list.
-func AddHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	user := strings.Trim(args, " \n")
-	config.DiscordModerators.Add(user)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Added %q to moderators", user))
+func AddHandler(c *CommandContext) {
+	user := strings.Trim(c.Args, " \n")
+	c.Bot.DiscordModerators.Add(user)
+	c.Reply(fmt.Sprintf("Added %q to moderators", user))
 }
 
 // RemoveHandler removes an admin from the moderators list
-func RemoveHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	user := strings.Trim(args, " \n")
-	config.DiscordModerators.Remove(user)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed %q from moderators", user))
+func RemoveHandler(c *CommandContext) {
+	user := strings.Trim(c.Args, " \n")
+	c.Bot.DiscordModerators.Remove(user)
+	c.Reply(fmt.Sprintf("Removed %q from moderators", user))
 }
 
 // PurgeHandler removes all moderators except the admin from the moderators list.
-func PurgeHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	config.DiscordModerators.Reset()
-	config.DiscordModerators.Add(config.DiscordAdmin)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Purged all moderators except %q", config.DiscordAdmin))
+func PurgeHandler(c *CommandContext) {
+	c.Bot.DiscordModerators.Reset()
+	c.Bot.DiscordModerators.Add(c.Bot.DiscordAdmin)
+	c.Reply(fmt.Sprintf("Purged all moderators except %q", c.Bot.DiscordAdmin))
 }
 
 // CleanHandler handles cleaning up a channel.
-func CleanHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	msg, _ := s.ChannelMessageSend(m.ChannelID, "starting channel cleanup...")
+func CleanHandler(c *CommandContext) {
+	msg, _ := c.Reply("starting channel cleanup...")
 
 	initialID := msg.ChannelID
-	for msgs, err := s.ChannelMessages(initialID, 100, msg.ID, "", ""); len(msgs) > 0 && err == nil; {
+	for msgs, err := c.Session.ChannelMessages(initialID, 100, msg.ID, "", ""); len(msgs) > 0 && err == nil; {
 		if err != nil {
 			log.Printf("error while cleaning up a channel: %s\n", err.Error())
 			break
@@ -134,94 +133,160 @@ func CleanHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args
 			msgIDs = append(msgIDs, msg.ID)
 		}
 
-		delErr := s.ChannelMessagesBulkDelete(msg.ChannelID, msgIDs)
+		delErr := c.Session.ChannelMessagesBulkDelete(msg.ChannelID, msgIDs)
 		if delErr != nil {
 			log.Printf("error while trying to bulk delete %d messages: %s", len(msgIDs), delErr)
-			s.ChannelMessageSend(msg.ChannelID, "The bot does not have enough permissions to cleanup the channel.")
+			c.Session.ChannelMessageSend(msg.ChannelID, "The bot does not have enough permissions to cleanup the channel.")
 
 			// delete initial message in any case.
-			s.ChannelMessageDelete(msg.ChannelID, msg.ID)
+			c.Session.ChannelMessageDelete(msg.ChannelID, msg.ID)
 			return
 		}
 	}
 
-	s.ChannelMessageDelete(msg.ChannelID, msg.ID)
-	s.ChannelMessageSend(m.ChannelID, "cleanup done!")
+	c.Session.ChannelMessageDelete(msg.ChannelID, msg.ID)
+	c.Reply("cleanup done!")
 }
 
 // ModerateHandler starts the connection between the game server and discord.
-func ModerateHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	if args == "" {
-		s.ChannelMessageSend(m.ChannelID, "please pass your server econ address.")
+func ModerateHandler(c *CommandContext) {
+	if c.Args == "" {
+		c.Reply("please pass your server econ address.")
 		return
 	}
-	addr := Address(strings.TrimSpace(args))
-	pass, ok := config.EconPasswords[addr]
+	addr := Address(strings.TrimSpace(c.Args))
+	pass, ok := c.Bot.EconPasswords[addr]
 
 	if !ok {
-		s.ChannelMessageSend(m.ChannelID, "unknown server address")
+		c.Reply("unknown server address")
 		return
 	}
 
-	currentChannel := discordChannel(m.ChannelID)
-
 	// handle single time registration with a discord channel
-	if config.ChannelAddress.AlreadyRegistered(addr) {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("The address %s is already registered with a channel.", addr))
+	if c.Bot.ChannelAddress.AlreadyRegistered(addr) {
+		c.Reply(fmt.Sprintf("The address %s is already registered with a channel.", addr))
 		return
 	}
-	config.ChannelAddress.Set(currentChannel, addr)
+	c.Bot.ChannelAddress.Set(ChannelBinding{
+		ChannelID: discordChannel(c.Message.ChannelID),
+		GuildID:   c.Message.GuildID,
+		Address:   addr,
+		MessageID: c.Message.ID,
+	})
+	c.Bot.SaveBindings()
+
+	// cleanup all messages before the initial message
+	go cleanupRoutine(c.Bot.ctx, c.Session, c.Message.ChannelID, c.Message.ID)
 
 	// start routine to listen to specified server.
-	go serverRoutine(globalCtx, s, m, addr, pass)
+	c.Bot.startServerRoutine(c.Session, addr, pass)
+
+	c.Reply(fmt.Sprintf("Started listening to server %s", addr))
+}
+
+// UnmoderateHandler stops the moderation of the server that is bound to the current channel
+// or of the server with the passed address.
+func UnmoderateHandler(c *CommandContext) {
+	addr := Address(strings.TrimSpace(c.Args))
+	if addr == "" {
+		channelAddr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+		if !ok {
+			c.Reply("this channel is not bound to any server.")
+			return
+		}
+		addr = channelAddr
+	}
+
+	if !c.Bot.ServerRoutines.Cancel(addr) {
+		c.Reply(fmt.Sprintf("The address %s is not registered with any channel.", addr))
+		return
+	}
+
+	c.Bot.ChannelAddress.RemoveAddress(addr)
+	c.Bot.SaveBindings()
+
+	c.Reply(fmt.Sprintf("Stopped listening to server %s", addr))
+}
+
+// RebindHandler moves the moderation of an already moderated server to the current channel
+// without dropping the econ connection.
+func RebindHandler(c *CommandContext) {
+	if c.Args == "" {
+		c.Reply("please pass your server econ address.")
+		return
+	}
+	addr := Address(strings.TrimSpace(c.Args))
+	currentChannel := discordChannel(c.Message.ChannelID)
 
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Started listening to server %s", addr))
+	if boundAddr, ok := c.Bot.ChannelAddress.Get(currentChannel); ok {
+		if boundAddr == addr {
+			c.Reply(fmt.Sprintf("The address %s is already registered with this channel.", addr))
+		} else {
+			c.Reply(fmt.Sprintf("This channel is already registered with the address %s.", boundAddr))
+		}
+		return
+	}
+
+	previousChannel, ok := c.Bot.ChannelAddress.Move(ChannelBinding{
+		ChannelID: currentChannel,
+		GuildID:   c.Message.GuildID,
+		Address:   addr,
+		MessageID: c.Message.ID,
+	})
+	if !ok {
+		c.Reply(fmt.Sprintf("The address %s is not registered with any channel.", addr))
+		return
+	}
+	c.Bot.SaveBindings()
+
+	c.Session.ChannelMessageSend(string(previousChannel), fmt.Sprintf("Moved server %s to <#%s>", addr, currentChannel))
+	c.Reply(fmt.Sprintf("Started listening to server %s", addr))
 }
 
 // SpyHandler starts spying on a specific player's whisper messages.
-func SpyHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	nickname := strings.Trim(args, " \n")
-	config.SpiedOnPlayers.Add(nickname)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Spying on %q ", nickname))
+func SpyHandler(c *CommandContext) {
+	nickname := strings.Trim(c.Args, " \n")
+	c.Bot.SpiedOnPlayers.Add(nickname)
+	c.Reply(fmt.Sprintf("Spying on %q ", nickname))
 }
 
 // UnspyHandler stopy the whisper messages spying.
-func UnspyHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	nickname := strings.Trim(args, " \n")
-	config.SpiedOnPlayers.Remove(nickname)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Stopped spying on %q", nickname))
+func UnspyHandler(c *CommandContext) {
+	nickname := strings.Trim(c.Args, " \n")
+	c.Bot.SpiedOnPlayers.Remove(nickname)
+	c.Reply(fmt.Sprintf("Stopped spying on %q", nickname))
 }
 
 // PurgeSpyHandler removes all the players from the spied on player list.
-func PurgeSpyHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	config.SpiedOnPlayers.Reset()
-	s.ChannelMessageSend(m.ChannelID, "Purged all spied on players.")
+func PurgeSpyHandler(c *CommandContext) {
+	c.Bot.SpiedOnPlayers.Reset()
+	c.Reply("Purged all spied on players.")
 }
 
 // ExecuteHandler allows to execute any econ command.
-func ExecuteHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
+func ExecuteHandler(c *CommandContext) {
 	// send other messages this way
-	addr, ok := config.GetAddressByChannelID(m.ChannelID)
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
 	if !ok {
 		return
 	}
 
-	config.DiscordCommandQueue[addr] <- command{Author: author, Command: args}
+	c.Bot.queueCommand(addr, command{Author: c.Author, Command: c.Args})
 }
 
 var bulkBanRegex = regexp.MustCompile(`^(.+) ([\dhmHM]+) (.+)$`)
 
-// BulkMultibanHandler bans all given IPs on all registered and active servers.
-func BulkMultibanHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
+// BulkMultibanHandler bans all given IPs, CIDR networks and IP ranges on all registered and active servers.
+func BulkMultibanHandler(c *CommandContext) {
 	// command must be executed in a connected channel.
-	_, ok := config.GetAddressByChannelID(m.ChannelID)
+	_, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
 	if !ok {
 		return
 	}
 
-	matches := bulkBanRegex.FindStringSubmatch(args)
+	matches := bulkBanRegex.FindStringSubmatch(c.Args)
 	if len(matches) != 4 {
-		s.ChannelMessageSend(m.ChannelID, "invalid argument syntax, expected: #bulkmultiban 123.0.0.1 123.0.0.2 123.0.0.3 [...] 1h5m reason for ban")
+		c.Reply("invalid argument syntax, expected: #bulkmultiban 123.0.0.1 123.0.0.0/24 123.0.0.1-123.0.0.20 [...] 1h5m reason for ban")
 		return
 	}
 
@@ -231,16 +296,16 @@ func BulkMultibanHandler(s *discordgo.Session, m *discordgo.MessageCreate, autho
 	duration, err := time.ParseDuration(durationString)
 
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("invalid ban duration: %q", durationString))
+		c.Reply(fmt.Sprintf("invalid ban duration: %q", durationString))
 		return
 	}
 
-	cleanIPs := make([]net.IP, 0, len(dirtyIPs))
+	cleanRanges := make([]IPRange, 0, len(dirtyIPs))
 	invalidIPs := make([]string, 0, 1)
 
 	for _, ip := range dirtyIPs {
-		if parsedIP := net.ParseIP(ip); parsedIP != nil {
-			cleanIPs = append(cleanIPs, parsedIP)
+		if parsedRange, err := ParseIPRange(ip); err == nil {
+			cleanRanges = append(cleanRanges, parsedRange)
 		} else {
 			invalidIPs = append(invalidIPs, ip)
 		}
@@ -250,26 +315,44 @@ func BulkMultibanHandler(s *discordgo.Session, m *discordgo.MessageCreate, autho
 	sort.Sort(byName(invalidIPs))
 
 	// sort valid IPs in reverse order in order for them to be properly sorted in the banlist
-	sort.Slice(cleanIPs, func(i, j int) bool {
-		return bytes.Compare(cleanIPs[i], cleanIPs[j]) > 0
+	sort.Slice(cleanRanges, func(i, j int) bool {
+		return bytes.Compare(cleanRanges[i].First, cleanRanges[j].First) > 0
 	})
 
-	for _, ip := range cleanIPs {
-
-		cmd := command{
-			Author:  author,
-			Command: fmt.Sprintf("ban %s %d %s", ip.String(), int(duration.Minutes()), reason),
+	numBanned := 0
+	pendingIDs := make([]string, 0, 1)
+
+	for _, ipRange := range cleanRanges {
+		if ipRange.Wide() {
+			pendingID := c.Bot.PendingRangeBans.Add(PendingRangeBan{
+				Global:   true,
+				Range:    ipRange,
+				Duration: duration,
+				Reason:   reason,
+				Author:   c.Author,
+			})
+			pendingIDs = append(pendingIDs, fmt.Sprintf("%d: %s (%s IPs)", pendingID, ipRange, ipRange.Size()))
+			continue
 		}
 
-		for _, queue := range config.GetCommandQueues() {
-			queue <- cmd
-		}
+		c.Bot.banOnAllServers(NewGlobalBan(Player{IP: ipRange.String()}, duration, reason, c.Author))
+		numBanned++
 	}
 
 	sb := strings.Builder{}
 
-	// print number of banned valid IPs
-	sb.WriteString(fmt.Sprintf("**Banned IPs**: %d\n", len(cleanIPs)))
+	// print number of banned valid IPs and ranges
+	sb.WriteString(fmt.Sprintf("**Banned IPs**: %d\n", numBanned))
+
+	// print ranges that are too wide
+	if len(pendingIDs) > 0 {
+		sb.WriteString("**Unconfirmed ranges**, confirm with #confirmban <ID>:\n```\n")
+		for _, pending := range pendingIDs {
+			sb.WriteString(pending)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```\n")
+	}
 
 	// print invalid IPs
 	if len(invalidIPs) > 0 {
@@ -282,5 +365,192 @@ func BulkMultibanHandler(s *discordgo.Session, m *discordgo.MessageCreate, autho
 	}
 
 	// send to channel
-	SplitChannelMessageSend(s, m, sb.String())
+	c.ReplySplit(sb.String())
+}
+
+// ConfirmBanHandler bans an IP range that is too wide to be banned without the confirmation of the administrator.
+func ConfirmBanHandler(c *CommandContext) {
+	id, err := strconv.Atoi(strings.TrimSpace(c.Args))
+	if err != nil {
+		c.Reply("invalid id argument")
+		return
+	}
+
+	pending, err := c.Bot.PendingRangeBans.Confirm(id)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	if pending.Global {
+		c.Bot.banOnAllServers(NewGlobalBan(Player{IP: pending.Range.String()}, pending.Duration, pending.Reason, pending.Author))
+		c.Reply(fmt.Sprintf("Banned %s IPs of %s's range ban on all servers", pending.Range.Size(), pending.Author))
+		return
+	}
+
+	// the command queue is only processed while the server is bound to a channel
+	ok := c.Bot.queueCommand(pending.Server, command{
+		Author:  pending.Author,
+		Command: banCommand(pending.Range.String(), int(pending.Duration.Minutes()), pending.Reason),
+	})
+	if !ok {
+		c.Reply(fmt.Sprintf("could not ban %s's range ban, %s is not moderated anymore", pending.Author, pending.Server))
+		return
+	}
+	c.Reply(fmt.Sprintf("Banned %s IPs of %s's range ban on %s", pending.Range.Size(), pending.Author, pending.Server))
+}
+
+// ExportBansHandler uploads the ban list of the current server or the global ban list as a file.
+func ExportBansHandler(c *CommandContext) {
+	format := banFormatJSON
+	global := false
+
+	for _, arg := range strings.Fields(c.Args) {
+		switch arg = strings.ToLower(arg); arg {
+		case banFormatJSON, banFormatCSV, banFormatCfg:
+			format = arg
+		case "global":
+			global = true
+		default:
+			c.Reply("invalid argument syntax, expected: #exportbans [json|csv|cfg] [global]")
+			return
+		}
+	}
+
+	var (
+		records  []BanRecord
+		filename string
+	)
+
+	if global {
+		records = BanRecordsFromGlobalBans(c.Bot.GlobalBans.Bans())
+		filename = "globalbans." + format
+	} else {
+		addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+		if !ok {
+			return
+		}
+		records = BanRecordsFromBans(c.Bot.ServerStates[addr].BanServer.Bans())
+		filename = fmt.Sprintf("bans_%s.%s", strings.ReplaceAll(string(addr), ":", "_"), format)
+	}
+
+	data, err := EncodeBans(format, records)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	_, err = c.Session.ChannelFileSend(c.Message.ChannelID, filename, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("error while uploading %s: %s", filename, err.Error())
+		c.Reply("The bot does not have enough permissions to upload files.")
+	}
+}
+
+// ImportBansHandler bans all valid entries of the attached ban file either on the current server or globally.
+func ImportBansHandler(c *CommandContext) {
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+	if !ok {
+		return
+	}
+
+	global := false
+	switch strings.ToLower(strings.TrimSpace(c.Args)) {
+	case "":
+	case "global":
+		global = true
+	default:
+		c.Reply("invalid argument syntax, expected: #importbans [global] with an attached json, csv or cfg file")
+		return
+	}
+
+	if len(c.Message.Attachments) == 0 {
+		c.Reply("please attach a json, csv or cfg ban file.")
+		return
+	}
+	attachment := c.Message.Attachments[0]
+
+	format, err := banFormatByFilename(attachment.Filename)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	if attachment.Size > maxBanFileSize {
+		c.Reply(fmt.Sprintf("the ban file must not be bigger than %d KiB.", maxBanFileSize/1024))
+		return
+	}
+
+	resp, err := banFileClient.Get(attachment.URL)
+	if err != nil {
+		c.Reply(fmt.Sprintf("could not download %s: %s", attachment.Filename, err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.Reply(fmt.Sprintf("could not download %s: %s", attachment.Filename, resp.Status))
+		return
+	}
+
+	records, invalid, err := DecodeBans(format, io.LimitReader(resp.Body, maxBanFileSize))
+	if err != nil {
+		c.Reply(fmt.Sprintf("invalid ban file %s: %s", attachment.Filename, err.Error()))
+		return
+	}
+
+	applied := 0
+	pendingIDs := make([]string, 0, 1)
+
+	for _, record := range records {
+		duration := time.Duration(record.Minutes) * time.Minute
+
+		// validated by DecodeBans
+		ipRange, _ := ParseIPRange(record.IP)
+		if ipRange.Wide() {
+			pendingID := c.Bot.PendingRangeBans.Add(PendingRangeBan{
+				Global:   global,
+				Server:   addr,
+				Range:    ipRange,
+				Duration: duration,
+				Reason:   record.Reason,
+				Author:   c.Author,
+			})
+			pendingIDs = append(pendingIDs, fmt.Sprintf("%d: %s (%s IPs)", pendingID, ipRange, ipRange.Size()))
+			continue
+		}
+
+		if global {
+			c.Bot.banOnAllServers(NewGlobalBan(Player{IP: record.IP, Name: record.Name}, duration, record.Reason, c.Author))
+		} else {
+			c.Bot.queueCommand(addr, command{
+				Author:  c.Author,
+				Command: banCommand(record.IP, record.Minutes, record.Reason),
+			})
+		}
+		applied++
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("**Imported bans** of %s: %d applied, %d invalid\n", attachment.Filename, applied, len(invalid)))
+
+	if len(pendingIDs) > 0 {
+		sb.WriteString("**Unconfirmed ranges**, confirm with #confirmban <ID>:\n```\n")
+		for _, pending := range pendingIDs {
+			sb.WriteString(pending)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```\n")
+	}
+
+	if len(invalid) > 0 {
+		sb.WriteString("**Invalid entries**:\n```\n")
+		for _, entry := range invalid {
+			sb.WriteString(entry)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```\n")
+	}
+
+	c.ReplySplit(sb.String())
 }
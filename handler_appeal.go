@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	appealAcceptButtonPrefix = "appeal_accept:"
+	appealDenyButtonPrefix   = "appeal_deny:"
+)
+
+// appealComponents creates the accept and deny buttons of a forwarded appeal.
+func appealComponents(id int) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Accept",
+					Style:    discordgo.SuccessButton,
+					CustomID: fmt.Sprintf("%s%d", appealAcceptButtonPrefix, id),
+				},
+				discordgo.Button{
+					Label:    "Deny",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%s%d", appealDenyButtonPrefix, id),
+				},
+			},
+		},
+	}
+}
+
+// appealChannel returns the channel that appeals are forwarded to, either the configured one
+// or the channel of the first server that the player is banned on.
+func (b *Bot) appealChannel(bans []AppealBan) (string, bool) {
+	if b.AppealsChannel != "" {
+		return b.AppealsChannel, true
+	}
+	return b.GetChannelIDByAddress(bans[0].Server)
+}
+
+// sendDirectMessage sends a message to a user's direct message channel.
+func sendDirectMessage(s DiscordSession, userID, content string) {
+	channel, err := s.UserChannelCreate(userID)
+	if err != nil {
+		log.Printf("error while creating a direct message channel: %s", err.Error())
+		return
+	}
+
+	_, err = s.ChannelMessageSend(channel.ID, content)
+	if err != nil {
+		log.Printf("error while sending a direct message: %s", err.Error())
+	}
+}
+
+// DirectMessageHandler handles the direct messages of users, which can appeal their bans.
+// The first line contains the command, the following lines are the appeal message.
+func (b *Bot) DirectMessageHandler(s DiscordSession, m *discordgo.MessageCreate) {
+	lines := strings.SplitN(strings.TrimSpace(m.Content), "\n", 2)
+	fields := strings.SplitN(strings.TrimSpace(lines[0]), " ", 2)
+
+	if fields[0] != "?appeal" || len(fields) != 2 || strings.TrimSpace(fields[1]) == "" {
+		s.ChannelMessageSend(m.ChannelID, "In order to appeal a ban, send `?appeal <nickname>` followed by your message in the next lines.")
+		return
+	}
+
+	nickname := strings.TrimSpace(fields[1])
+	message := ""
+	if len(lines) == 2 {
+		message = strings.TrimSpace(lines[1])
+	}
+
+	bans := b.findBansByNickname(nickname)
+	if len(bans) == 0 {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("There are no active bans of '%s'.", Escape(nickname)))
+		return
+	}
+
+	channelID, ok := b.appealChannel(bans)
+	if !ok {
+		s.ChannelMessageSend(m.ChannelID, "Your appeal could not be forwarded, please try again later.")
+		return
+	}
+
+	appeal, err := b.Appeals.Open(Appeal{
+		UserID:   m.Author.ID,
+		User:     m.Author.String(),
+		Nickname: nickname,
+		Message:  message,
+		Bans:     bans,
+	})
+	if err != nil {
+		s.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:    appeal.String(),
+		Components: appealComponents(appeal.ID),
+	})
+	if err != nil {
+		log.Printf("error while forwarding appeal #%d: %s", appeal.ID, err.Error())
+		b.Appeals.Remove(appeal.ID)
+		s.ChannelMessageSend(m.ChannelID, "Your appeal could not be forwarded, please try again later.")
+		return
+	}
+	b.Appeals.SetMessage(appeal.ID, msg.ChannelID, msg.ID)
+
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Your appeal #%d has been forwarded to the moderators, you will be notified once they decided.", appeal.ID))
+}
+
+// AppealButtonHandler accepts or denies an appeal, accepted appeals unban all appealed bans.
+func (b *Bot) AppealButtonHandler(s DiscordSession, i *discordgo.InteractionCreate, customID string) {
+	status := AppealDenied
+	idText := strings.TrimPrefix(customID, appealDenyButtonPrefix)
+	if strings.HasPrefix(customID, appealAcceptButtonPrefix) {
+		status = AppealAccepted
+		idText = strings.TrimPrefix(customID, appealAcceptButtonPrefix)
+	}
+
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		respondEphemeral(s, i, ErrAppealNotFound.Error())
+		return
+	}
+
+	moderator := interactionAuthor(i)
+	appeal, err := b.Appeals.Decide(id, status, moderator)
+	if err == ErrAppealNotFound {
+		respondEphemeral(s, i, fmt.Sprintf("%s, the bot might have been restarted.", err.Error()))
+		return
+	}
+
+	// already decided appeals only update the message
+	err2 := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    appeal.String(),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err2 != nil {
+		log.Printf("error while updating appeal #%d: %s", appeal.ID, err2.Error())
+	}
+
+	if err != nil {
+		return
+	}
+
+	if status == AppealDenied {
+		sendDirectMessage(s, appeal.UserID, fmt.Sprintf("Your appeal #%d has been denied.", appeal.ID))
+		return
+	}
+
+	for _, ban := range appeal.Bans {
+		// the server was unbound in the meantime
+		if !b.ChannelAddress.AlreadyRegistered(ban.Server) {
+			continue
+		}
+
+		b.queueCommand(ban.Server, command{
+			Author:  moderator,
+			Command: unbanCommand(ban.Ban.Player.IP),
+		})
+	}
+	sendDirectMessage(s, appeal.UserID, fmt.Sprintf("Your appeal #%d has been accepted, you have been unbanned.", appeal.ID))
+}
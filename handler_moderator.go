@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -11,39 +12,39 @@ import (
 )
 
 // HelpHandler handles the ?help command and prints a help screen
-func HelpHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
+func HelpHandler(c *CommandContext) {
 	// help is not part of the commands
 	sb := strings.Builder{}
 	sb.WriteString("Available Commands: \n")
 	sb.WriteString("```")
-	for _, cmd := range config.DiscordModeratorCommands.Commands() {
+	for _, cmd := range c.Bot.DiscordModeratorCommands.Commands() {
 		sb.WriteString(fmt.Sprintf("?%s\n", cmd))
 	}
 	sb.WriteString("```")
 
 	sb.WriteString("Moderators:\n")
 	sb.WriteString("```")
-	for _, moderator := range config.DiscordModerators.Users() {
+	for _, moderator := range c.Bot.DiscordModerators.Users() {
 		sb.WriteString(fmt.Sprintf("%s\n", moderator))
 	}
 	sb.WriteString("```")
 
-	SplitChannelMessageSend(s, m, sb.String())
+	c.ReplySplit(sb.String())
 }
 
 // StatusHandler handles the ?status command
-func StatusHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	srv, _ := config.GetServerByChannelID(m.ChannelID)
+func StatusHandler(c *CommandContext) {
+	srv, _ := c.Bot.GetServerByChannelID(c.Message.ChannelID)
 
 	// handle status from cache data
 	players := srv.Status()
 
 	if len(players) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "There are currently no players online.")
+		c.Reply("There are currently no players online.")
 		return
 	}
 
-	canSeeIPs := (args == "ips" || args == "ip") && config.DiscordAdmin == author
+	canSeeIPs := (c.Args == "ips" || c.Args == "ip") && c.Bot.DiscordAdmin == c.Author
 
 	sb := strings.Builder{}
 	sb.Grow(128 * len(players))
@@ -66,136 +67,336 @@ func StatusHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, arg
 		sb.WriteString(line)
 	}
 
-	SplitChannelMessageSend(s, m, sb.String())
+	c.ReplySplit(sb.String())
 }
 
-// BansHandler shows the server specific bans list.
-func BansHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	srv, ok := config.GetServerByChannelID(m.ChannelID)
+// BansHandler shows the server specific bans list, optionally filtered and sorted.
+// Long ban lists are paginated with buttons that edit the message.
+func BansHandler(c *CommandContext) {
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
 	if !ok {
 		return
 	}
 
-	banSrv := &srv.BanServer
+	filter, err := ParseBanFilter(c.Args)
+	if err != nil {
+		c.Reply(fmt.Sprintf("**[error]**: %s", err.Error()))
+		return
+	}
+
+	// IPs are not visible to moderators
+	if filter.IP != "" && c.Bot.DiscordAdmin != c.Author {
+		c.Reply("**[error]**: only the administrator can filter by IP")
+		return
+	}
+
+	content, page, pages := bansPage(c.Bot.ServerStates[addr].BanServer.Bans(), filter, 0)
+	if pages <= 1 {
+		c.Reply(content)
+		return
+	}
 
-	numBans := banSrv.Size()
-	if numBans == 0 {
-		s.ChannelMessageSend(m.ChannelID, "[banlist]: 0 ban(s)")
+	msg, err := c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:    content,
+		Components: bansPageComponents(page, pages, false),
+	})
+	if err != nil {
+		log.Printf("error while sending the ban list: %s", err.Error())
 		return
 	}
-	msg := fmt.Sprintf("[banlist]: %d ban(s)\n```%s```\n", numBans, banSrv.String())
 
-	SplitChannelMessageSend(s, m, msg)
+	c.Bot.BansPages.Set(msg.ID, bansPages{
+		Addr:      addr,
+		Filter:    filter,
+		Page:      page,
+		CreatedAt: time.Now(),
+	})
 }
 
 // MultiBanHandler allows to ban a specific player on all moderated servers at once.
-func MultiBanHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	server, ok := config.GetServerByChannelID(m.ChannelID)
+// If the minutes are omitted, the ban duration is escalated based on the player's previous bans.
+func MultiBanHandler(c *CommandContext) {
+	server, ok := c.Bot.GetServerByChannelID(c.Message.ChannelID)
 	if !ok {
-		log.Printf("requested multiban from invalid channel by: %s", author)
+		log.Printf("requested multiban from invalid channel by: %s", c.Author)
 		return
 	}
 
-	id := -1
 	minutes := 0
 	reason := ""
 
-	cmdTokens := strings.SplitN(args, " ", 3)
+	cmdTokens := strings.SplitN(c.Args, " ", 3)
+	if len(cmdTokens) < 2 {
+		c.Reply("**[error]**: invalid argument syntax, expected: ?multiban <ID|IP range> [minutes] <reason>")
+		return
+	}
+
+	var player Player
+	ipRange := IPRange{}
 
 	id, err := strconv.Atoi(cmdTokens[0])
-	if err != nil || id < 0 {
-		s.ChannelMessageSend(m.ChannelID, "**[error]**: invalid user ID")
+	if err == nil && id >= 0 {
+		player = server.Player(id)
+		if player.IP == "" {
+			c.Reply("**[error]**: no player with this ID online")
+			return
+		}
+	} else if ipRange, err = ParseIPRange(cmdTokens[0]); err == nil {
+		// CIDR networks and ranges are banned with ban_range
+		player = server.PlayerByIP(ipRange.String())
+	} else {
+		c.Reply("**[error]**: invalid user ID or IP range")
 		return
 	}
 
 	minutes, err = strconv.Atoi(cmdTokens[1])
-	if err != nil || minutes <= 0 {
-		s.ChannelMessageSend(m.ChannelID, "**[error]**: invalid minutes argument, please enter an integer.")
+	if err != nil {
+		// no minutes passed, escalate
+		offenses := 0
+		minutes, offenses = c.Bot.escalatedBanMinutes(player)
+		reason = strings.Join(cmdTokens[1:], " ")
+
+		c.Reply(fmt.Sprintf("**[multiban]**: '%s' has %d previous offense(s), banning for %s", Escape(player.Name), offenses, time.Duration(minutes)*time.Minute))
+	} else if minutes <= 0 {
+		c.Reply("**[error]**: invalid minutes argument, please enter an integer.")
+		return
+	} else if len(cmdTokens) == 3 {
+		reason = cmdTokens[2]
+	}
+	duration := time.Duration(minutes) * time.Minute
+
+	if ipRange.First != nil && ipRange.Wide() {
+		pendingID := c.Bot.PendingRangeBans.Add(PendingRangeBan{
+			Global:   true,
+			Range:    ipRange,
+			Duration: duration,
+			Reason:   reason,
+			Author:   c.Author,
+		})
+		c.Reply(fmt.Sprintf("**[multiban]**: the range contains %s IPs, an administrator needs to confirm the ban with #confirmban %d", ipRange.Size(), pendingID))
 		return
 	}
 
-	reason = cmdTokens[2]
+	// servers that are offline or bound later receive the ban when they connect
+	c.Bot.banOnAllServers(NewGlobalBan(player, duration, reason, c.Author))
+
+	if !player.Valid() {
+		return
+	}
 
-	player := server.Player(id)
-	ip := player.IP
+	// the servers report the bans asynchronously, don't block the handler while waiting for them
+	go c.Bot.setBannedPlayer(player)
+}
 
-	cmd := command{
-		Author:  m.Author.String(),
-		Command: fmt.Sprintf("ban %s %d %s", ip, minutes, reason),
+// setBannedPlayer sets the nickname of the bans that the servers report after the player was banned on all servers.
+// Servers that did not report the ban within ten seconds are skipped.
+func (b *Bot) setBannedPlayer(player Player) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	pending := b.GetServers()
+	for retries := 0; retries < 10 && len(pending) > 0; retries++ {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		remaining := pending[:0]
+		for _, server := range pending {
+			if !server.BanServer.SetPlayerAfterwards(player) {
+				remaining = append(remaining, server)
+			}
+		}
+		pending = remaining
 	}
+}
 
-	for _, queue := range config.GetCommandQueues() {
-		queue <- cmd
+// PunishHandler bans a player on the current server with a duration that is escalated based on the player's previous bans.
+func PunishHandler(c *CommandContext) {
+	addr, ok := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+	if !ok {
+		log.Printf("requested punish from invalid channel by: %s", c.Author)
+		return
 	}
 
-	// set player nickname on all servers
-	for _, server := range config.GetServers() {
+	cmdTokens := strings.SplitN(strings.TrimSpace(c.Args), " ", 2)
+	if len(cmdTokens) != 2 {
+		c.Reply("**[error]**: invalid argument syntax, expected: ?punish <ID> <reason>")
+		return
+	}
 
-		for retries := 0; retries < 10; retries++ {
-			time.Sleep(time.Second)
+	id, err := strconv.Atoi(cmdTokens[0])
+	if err != nil || id < 0 {
+		c.Reply("**[error]**: invalid user ID")
+		return
+	}
+	reason := cmdTokens[1]
 
-			if ok := server.BanServer.SetPlayerAfterwards(player); ok {
-				break
-			}
-		}
+	player := c.Bot.ServerStates[addr].Player(id)
+	if !player.Valid() {
+		c.Reply("**[error]**: no player with this ID online")
+		return
 	}
+
+	// the configured ban command is used, its duration is only escalated if it contains the minutes placeholder.
+	// The reason is inserted afterwards, as it must not contain any placeholders.
+	cmd := fmt.Sprintf(c.Bot.BanReplacementIDCommand, player.ID)
+	if strings.Contains(cmd, minutesPlaceholder) {
+		minutes, offenses := c.Bot.escalatedBanMinutes(player)
+		cmd = strings.ReplaceAll(cmd, minutesPlaceholder, strconv.Itoa(minutes))
+		c.Reply(fmt.Sprintf("**[punish]**: '%s' has %d previous offense(s), banning for %s", Escape(player.Name), offenses, time.Duration(minutes)*time.Minute))
+	} else {
+		c.Reply(fmt.Sprintf("**[punish]**: banning '%s'", Escape(player.Name)))
+	}
+	cmd = withReason(cmd, reason)
+
+	c.Bot.queueCommand(addr, command{
+		Author:  c.Author,
+		Command: cmd,
+	})
 }
 
 // MultiUnbanHandler allows to unban a specific IP from all registered servers.
-func MultiUnbanHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	server, ok := config.GetServerByChannelID(m.ChannelID)
+func MultiUnbanHandler(c *CommandContext) {
+	server, ok := c.Bot.GetServerByChannelID(c.Message.ChannelID)
+	if !ok {
+		log.Printf("requested multiunban from invalid channel by: %s", c.Message.Author.String())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Args)
+	if err != nil || id < 0 {
+		c.Reply("**[error]**: invalid ban ID")
+		return
+	}
+
+	ban, err := server.BanServer.GetBan(id)
+	if err != nil {
+		c.Reply(fmt.Sprintf("**[error]**: %s", err.Error()))
+		return
+	}
+
+	c.Bot.GlobalBans.Remove(ban.Player.IP)
+
+	c.Bot.queueCommandOnAllServers(command{
+		Author:  c.Author,
+		Command: unbanCommand(ban.Player.IP),
+	})
+}
+
+// GlobalBansHandler shows the bans that are enforced on all moderated servers.
+func GlobalBansHandler(c *CommandContext) {
+	bans := c.Bot.GlobalBans.Bans()
+	if len(bans) == 0 {
+		c.Reply("[global banlist]: 0 ban(s)")
+		return
+	}
+
+	msg := fmt.Sprintf("[global banlist]: %d ban(s)\n```%s```\n", len(bans), c.Bot.GlobalBans.String())
+	c.ReplySplit(msg)
+}
+
+// GlobalBanHandler marks a ban of the current server as global, which enforces it on all moderated servers.
+func GlobalBanHandler(c *CommandContext) {
+	server, ok := c.Bot.GetServerByChannelID(c.Message.ChannelID)
 	if !ok {
-		log.Printf("requested multiunban from invalid channel by: %s", m.Author.String())
+		log.Printf("requested globalban from invalid channel by: %s", c.Author)
 		return
 	}
 
-	id, err := strconv.Atoi(args)
+	id, err := strconv.Atoi(strings.TrimSpace(c.Args))
 	if err != nil || id < 0 {
-		s.ChannelMessageSend(m.ChannelID, "**[error]**: invalid ban ID")
+		c.Reply("**[error]**: invalid ban ID")
 		return
 	}
 
 	ban, err := server.BanServer.GetBan(id)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("**[error]**: %s", err.Error()))
+		c.Reply(fmt.Sprintf("**[error]**: %s", err.Error()))
 		return
 	}
 
-	for _, cmdQueue := range config.GetCommandQueues() {
-		cmdQueue <- command{
-			Author:  author,
-			Command: fmt.Sprintf("unban %s", ban.Player.IP),
+	globalBan := GlobalBan{
+		IP:        ban.Player.IP,
+		Name:      ban.Player.Name,
+		ExpiresAt: ban.ExpiresAt,
+		Reason:    ban.Reason,
+		Author:    c.Author,
+	}
+	c.Bot.GlobalBans.Add(globalBan)
+
+	cmd := command{
+		Author:  c.Author,
+		Command: globalBan.Command(),
+	}
+
+	addr, _ := c.Bot.GetAddressByChannelID(c.Message.ChannelID)
+	for _, boundAddr := range c.Bot.ChannelAddress.GetAddresses() {
+		if boundAddr != addr {
+			c.Bot.queueCommand(boundAddr, cmd)
 		}
 	}
+
+	c.Reply(fmt.Sprintf("**[globalban]**: '%s' is now banned on all servers", Escape(ban.Player.Name)))
+}
+
+// LocalBanHandler marks a global ban as server-local, the servers keep their bans, but the
+// ban is not enforced on servers that connect afterwards anymore.
+func LocalBanHandler(c *CommandContext) {
+	server, ok := c.Bot.GetServerByChannelID(c.Message.ChannelID)
+	if !ok {
+		log.Printf("requested localban from invalid channel by: %s", c.Author)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(c.Args))
+	if err != nil || id < 0 {
+		c.Reply("**[error]**: invalid ban ID")
+		return
+	}
+
+	ban, err := server.BanServer.GetBan(id)
+	if err != nil {
+		c.Reply(fmt.Sprintf("**[error]**: %s", err.Error()))
+		return
+	}
+
+	if _, ok := c.Bot.GlobalBans.Remove(ban.Player.IP); !ok {
+		c.Reply(fmt.Sprintf("**[error]**: '%s' is not banned globally", Escape(ban.Player.Name)))
+		return
+	}
+
+	c.Reply(fmt.Sprintf("**[localban]**: the ban of '%s' is now server-local", Escape(ban.Player.Name)))
 }
 
 // NotifyHandler registers a notification request that pings the registering moderator when the player joins
-func NotifyHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	config.JoinNotify.Add(m.Author.Mention(), args)
-	confirmationMessage := fmt.Sprintf("%s's notification request for '%s' received.", m.Author.Mention(), args)
-	s.ChannelMessageSend(m.ChannelID, confirmationMessage)
+func NotifyHandler(c *CommandContext) {
+	c.Bot.JoinNotify.Add(c.Message.Author.Mention(), c.Args)
+	confirmationMessage := fmt.Sprintf("%s's notification request for '%s' received.", c.Message.Author.Mention(), c.Args)
+	c.Reply(confirmationMessage)
 }
 
 // UnnotifyHandler removes all registered notification requests.
-func UnnotifyHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	config.JoinNotify.Remove(m.Author.Mention())
+func UnnotifyHandler(c *CommandContext) {
+	c.Bot.JoinNotify.Remove(c.Message.Author.Mention())
 
-	confirmationMessage := fmt.Sprintf("Removed all of %s's notification requests.", m.Author.Mention())
-	s.ChannelMessageSend(m.ChannelID, confirmationMessage)
+	confirmationMessage := fmt.Sprintf("Removed all of %s's notification requests.", c.Message.Author.Mention())
+	c.Reply(confirmationMessage)
 }
 
 // WhoisHandler associates different nicknames to each other based on IPs. This allows
 // to check, if a specific player is already known under a different nickname.
-func WhoisHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args string) {
-	nickname := strings.TrimSpace(args)
-	if config.NicknameTracker == nil {
-		s.ChannelMessageSend(m.ChannelID, "nickname tracking is disabled.")
+func WhoisHandler(c *CommandContext) {
+	nickname := strings.TrimSpace(c.Args)
+	if c.Bot.NicknameTracker == nil {
+		c.Reply("nickname tracking is disabled.")
 		return
 	}
 
-	nicknames, err := config.NicknameTracker.WhoIs(nickname)
+	nicknames, err := c.Bot.NicknameTracker.WhoIs(nickname)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
+		c.Reply(err.Error())
 		return
 	}
 
@@ -206,5 +407,75 @@ func WhoisHandler(s *discordgo.Session, m *discordgo.MessageCreate, author, args
 		sb.WriteString("\n")
 	}
 	sb.WriteString("```\n")
-	s.ChannelMessageSend(m.ChannelID, sb.String())
+	c.Reply(sb.String())
+}
+
+// BanHistoryHandler shows every ban, unban and ban expiry of a specific nickname or IP.
+func BanHistoryHandler(c *CommandContext) {
+	query := strings.TrimSpace(c.Args)
+	if c.Bot.BanHistory == nil {
+		c.Reply("ban history is disabled.")
+		return
+	}
+
+	if query == "" {
+		c.Reply("**[error]**: please pass a nickname or an IP")
+		return
+	}
+
+	var (
+		events []BanEvent
+		err    error
+	)
+
+	if net.ParseIP(query) != nil {
+		events, err = c.Bot.BanHistory.ByIP(query)
+	} else {
+		events, err = c.Bot.BanHistory.ByName(query)
+	}
+
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	if len(events) == 0 {
+		c.Reply(fmt.Sprintf("**No ban history** for `%s`", query))
+		return
+	}
+
+	canSeeIPs := c.Bot.DiscordAdmin == c.Author
+	numBans := 0
+
+	var sb strings.Builder
+	sb.WriteString("```\n")
+	for _, e := range events {
+		if e.Type == BanEventBan {
+			numBans++
+		}
+
+		by := "ingame"
+		if e.Author != "" {
+			by = e.Author
+		}
+
+		ip := ""
+		if canSeeIPs {
+			ip = " " + e.Player.IP
+		}
+
+		line := fmt.Sprintf("%s %-6s %s '%s'%s", e.Time.Format("2006-01-02 15:04"), e.Type, e.Server, e.Player.Name, ip)
+		if e.Type == BanEventBan {
+			line += fmt.Sprintf(" for %s by %s (%s)", formatBanDuration(e.Duration), by, e.Reason)
+		} else if e.Type == BanEventUnban {
+			line += fmt.Sprintf(" by %s", by)
+		}
+
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("```\n")
+
+	header := fmt.Sprintf("**Ban history** of `%s`: %d ban(s)\n", query, numBans)
+	c.ReplySplit(header + sb.String())
 }
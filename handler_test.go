@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jxsl13/TeeworldsEconDiscordModerationBot/discordtest"
+)
+
+const (
+	testChannelID = "channel"
+	testAddress   = Address("127.0.0.1:8303")
+)
+
+var (
+	testAdmin     = &discordgo.User{ID: "1", Username: "admin", Discriminator: "0001"}
+	testModerator = &discordgo.User{ID: "2", Username: "moderator", Discriminator: "0002"}
+	testUser      = &discordgo.User{ID: "3", Username: "user", Discriminator: "0003"}
+)
+
+// newTestBot creates a Bot with a single server that is bound to the test channel.
+func newTestBot() *Bot {
+	b := newBot()
+	b.DiscordAdmin = testAdmin.String()
+	b.DiscordModerators.Add(testAdmin.String())
+	b.DiscordModerators.Add(testModerator.String())
+	b.DiscordModeratorCommands.Add("status")
+	b.DiscordModeratorCommands.Add("punish")
+	b.BanReplacementIDCommand = banCommandFormat("", "ID", "%d")
+	b.BanReplacementIPCommand = banCommandFormat("", "IP", "%s")
+
+	b.ServerStates[testAddress] = NewServer()
+	b.DiscordCommandQueue[testAddress] = make(chan command, 16)
+	b.ChannelAddress.Set(ChannelBinding{
+		ChannelID: testChannelID,
+		Address:   testAddress,
+	})
+	return b
+}
+
+// sendCommand lets the user write a message to the test channel and returns the replies of the bot.
+func sendCommand(b *Bot, user *discordgo.User, content string) []string {
+	session := discordtest.NewSession()
+	b.CommandMessageHandler(session, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "100",
+			ChannelID: testChannelID,
+			GuildID:   "guild",
+			Author:    user,
+			Content:   content,
+		},
+	})
+
+	replies := make([]string, 0, 1)
+	for _, msg := range session.Messages(testChannelID) {
+		replies = append(replies, msg.Content)
+	}
+	return replies
+}
+
+func TestCommandMessageHandler_Permissions(t *testing.T) {
+	b := newTestBot()
+
+	tests := []struct {
+		user    *discordgo.User
+		content string
+		reply   string
+	}{
+		{testUser, "?status", "no access to moderator commands."},
+		{testModerator, "#add user#0003", "no access to admin commands."},
+		{testModerator, "?bans", "invalid command: bans"},
+		{testModerator, "?status", "There are currently no players online."},
+		{testAdmin, "#add user#0003", `Added "user#0003" to moderators`},
+		{testUser, "just chatting", ""},
+	}
+
+	for _, tt := range tests {
+		replies := sendCommand(b, tt.user, tt.content)
+		if tt.reply == "" {
+			if len(replies) != 0 {
+				t.Errorf("%s: expected no reply, got %q", tt.content, replies)
+			}
+			continue
+		}
+		if len(replies) != 1 || replies[0] != tt.reply {
+			t.Errorf("%s by %s: expected %q, got %q", tt.content, tt.user, tt.reply, replies)
+		}
+	}
+
+	if !b.DiscordModerators.Contains(testUser.String()) {
+		t.Errorf("expected %s to be a moderator", testUser)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	b := newTestBot()
+	b.ServerStates[testAddress].UpdateStatus(Player{
+		ID:      3,
+		Name:    "voter",
+		IP:      "192.168.178.25",
+		Port:    64139,
+		Country: -1,
+	})
+
+	replies := sendCommand(b, testModerator, "?status ips")
+	if len(replies) != 1 || strings.Contains(replies[0], "192.168.178.25") || !strings.Contains(replies[0], "`voter`") {
+		t.Errorf("moderators must see the players without IPs, got: %q", replies)
+	}
+
+	replies = sendCommand(b, testAdmin, "?status ips")
+	if len(replies) != 1 || !strings.Contains(replies[0], "`192.168.178.25`") {
+		t.Errorf("the admin must see the IPs, got: %q", replies)
+	}
+}
+
+func TestPunishHandler_Arguments(t *testing.T) {
+	b := newTestBot()
+
+	tests := map[string]string{
+		"?punish":         "**[error]**: invalid argument syntax, expected: ?punish <ID> <reason>",
+		"?punish 3":       "**[error]**: invalid argument syntax, expected: ?punish <ID> <reason>",
+		"?punish x spam":  "**[error]**: invalid user ID",
+		"?punish -1 spam": "**[error]**: invalid user ID",
+		"?punish 3 spam":  "**[error]**: no player with this ID online",
+	}
+
+	for content, expected := range tests {
+		replies := sendCommand(b, testModerator, content)
+		if len(replies) != 1 || replies[0] != expected {
+			t.Errorf("%s: expected %q, got %q", content, expected, replies)
+		}
+	}
+
+	if len(b.DiscordCommandQueue[testAddress]) != 0 {
+		t.Errorf("invalid arguments must not execute any command")
+	}
+}
+
+func TestPunishHandler_BanCommand(t *testing.T) {
+	b := newTestBot()
+	b.BanEscalation = defaultBanEscalation
+	b.ServerStates[testAddress].UpdateStatus(Player{ID: 5, Name: "griefer", IP: "192.168.178.26", Port: 64140, Country: -1})
+
+	tests := []struct {
+		template string
+		reason   string
+		expected string
+	}{
+		{"", "spam", "ban 5 5 spam"},
+		{"voteban {ID} {MINUTES}", "spam", "voteban 5 5"},
+		{"ban {id} 60 {reason} (bot)", "spam", "ban 5 60 spam (bot)"},
+		// placeholders within the reason are not replaced
+		{"", "spam {MINUTES}", "ban 5 5 spam {MINUTES}"},
+	}
+
+	for _, test := range tests {
+		template, expected := test.template, test.expected
+		b.BanReplacementIDCommand = banCommandFormat(template, "ID", "%d")
+		sendCommand(b, testModerator, "?punish 5 "+test.reason)
+
+		select {
+		case cmd := <-b.DiscordCommandQueue[testAddress]:
+			if cmd.Command != expected {
+				t.Errorf("%q: expected %q, got %q", template, expected, cmd.Command)
+			}
+		default:
+			t.Errorf("%q: player was not punished", template)
+		}
+	}
+}
+
+func TestConfirmBanHandler_Server(t *testing.T) {
+	b := newTestBot()
+	ipRange, err := ParseIPRange("10.0.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, server := range []Address{"127.0.0.1:9999", testAddress} {
+		b.PendingRangeBans.Add(PendingRangeBan{
+			Server:   server,
+			Range:    ipRange,
+			Duration: time.Hour,
+			Reason:   "proxies",
+			Author:   testModerator.String(),
+		})
+	}
+
+	// servers that are not bound anymore cannot process the ban
+	sendCommand(b, testAdmin, "#confirmban 1")
+	sendCommand(b, testAdmin, "#confirmban 2")
+
+	select {
+	case cmd := <-b.DiscordCommandQueue[testAddress]:
+		if cmd.Command != "ban_range 10.0.0.0 10.0.255.255 60 proxies" {
+			t.Errorf("unexpected command: %#v", cmd)
+		}
+	default:
+		t.Fatalf("range was not banned")
+	}
+	if len(b.DiscordCommandQueue[testAddress]) != 0 {
+		t.Errorf("range bans of a server must only be executed on that server")
+	}
+}
+
+func TestUnmoderateHandler_QueuedCommands(t *testing.T) {
+	b := newTestBot()
+
+	// the command queue is not processed, as the server routine is stuck
+	b.DiscordCommandQueue[testAddress] = make(chan command)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ServerRoutines.Set(testAddress, ctx, cancel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendCommand(b, testAdmin, "#vote no")
+	}()
+
+	// let the handler block on the command queue
+	time.Sleep(100 * time.Millisecond)
+	sendCommand(b, testAdmin, "#unmoderate")
+
+	select {
+	case <-done:
+	case <-time.After(commandQueueTimeout / 2):
+		t.Fatal("queued command blocked after the server was unmoderated")
+	}
+}
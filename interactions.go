@@ -0,0 +1,180 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	bansPrevButtonID = "bans_prev"
+	bansNextButtonID = "bans_next"
+
+	// the navigation buttons of ?bans stop working after this time
+	bansPagesTimeout = 15 * time.Minute
+)
+
+// bansPages is the state of a paginated ?bans message.
+type bansPages struct {
+	Addr      Address
+	Filter    BanFilter
+	Page      int
+	CreatedAt time.Time
+}
+
+// BansPagesMap maps the IDs of paginated ?bans messages to their state.
+type BansPagesMap struct {
+	mu    sync.Mutex
+	pages map[string]bansPages
+}
+
+// Set adds or updates the state of a paginated message.
+func (m *BansPagesMap) Set(messageID string, pages bansPages) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pages == nil {
+		m.pages = make(map[string]bansPages)
+	}
+
+	for id, p := range m.pages {
+		if time.Since(p.CreatedAt) > bansPagesTimeout {
+			delete(m.pages, id)
+		}
+	}
+	m.pages[messageID] = pages
+}
+
+// Get returns the state of a paginated message that did not time out yet.
+func (m *BansPagesMap) Get(messageID string) (bansPages, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pages[messageID]
+	if !ok || time.Since(p.CreatedAt) > bansPagesTimeout {
+		delete(m.pages, messageID)
+		return bansPages{}, false
+	}
+	return p, true
+}
+
+// bansPageComponents creates the previous and next buttons of a paginated ?bans message.
+func bansPageComponents(page, pages int, disabled bool) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: bansPrevButtonID,
+					Disabled: disabled || page <= 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: bansNextButtonID,
+					Disabled: disabled || page >= pages-1,
+				},
+			},
+		},
+	}
+}
+
+// interactionUser returns the user that clicked a button or executed a slash command,
+// either in a guild or in a direct message.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// interactionAuthor returns the name of the user that interacted with the bot.
+func interactionAuthor(i *discordgo.InteractionCreate) string {
+	if user := interactionUser(i); user != nil {
+		return user.String()
+	}
+	return ""
+}
+
+// respondEphemeral answers an interaction with a message that only the clicking user can see.
+func respondEphemeral(s DiscordSession, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("error while responding to an interaction: %s", err.Error())
+	}
+}
+
+// InteractionCreateHandler dispatches the slash commands, their autocompletion and the clicks on message components.
+func (b *Bot) InteractionCreateHandler(s DiscordSession, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.ApplicationCommandHandler(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		b.AutocompleteHandler(s, i)
+	case discordgo.InteractionMessageComponent:
+		b.MessageComponentHandler(s, i)
+	}
+}
+
+// MessageComponentHandler dispatches the clicks on message components.
+func (b *Bot) MessageComponentHandler(s DiscordSession, i *discordgo.InteractionCreate) {
+	author := interactionAuthor(i)
+	if !b.DiscordModerators.Contains(author) {
+		respondEphemeral(s, i, "you are not allowed to access this command.")
+		return
+	}
+
+	switch customID := i.MessageComponentData().CustomID; {
+	case customID == bansPrevButtonID, customID == bansNextButtonID:
+		b.BansPageHandler(s, i, customID)
+	case strings.HasPrefix(customID, appealAcceptButtonPrefix), strings.HasPrefix(customID, appealDenyButtonPrefix):
+		b.AppealButtonHandler(s, i, customID)
+	case customID == voteYesButtonID, customID == voteNoButtonID, customID == voteBanButtonID, customID == unbanButtonID:
+		b.ModerationButtonHandler(s, i, customID)
+	}
+}
+
+// BansPageHandler edits a paginated ?bans message to show the previous or the next page.
+func (b *Bot) BansPageHandler(s DiscordSession, i *discordgo.InteractionCreate, customID string) {
+	pages, ok := b.BansPages.Get(i.Message.ID)
+
+	data := &discordgo.InteractionResponseData{
+		Content: i.Message.Content,
+	}
+
+	if !ok {
+		// timed out, disable the buttons
+		data.Components = bansPageComponents(0, 0, true)
+	} else {
+		if customID == bansNextButtonID {
+			pages.Page++
+		} else {
+			pages.Page--
+		}
+
+		content, page, numPages := bansPage(b.ServerStates[pages.Addr].BanServer.Bans(), pages.Filter, pages.Page)
+		pages.Page = page
+		b.BansPages.Set(i.Message.ID, pages)
+
+		data.Content = content
+		data.Components = bansPageComponents(page, numPages, false)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+	if err != nil {
+		log.Printf("error while updating the ban list page: %s", err.Error())
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ranges with more addresses than a /24 (IPv4) or a /64 (IPv6) network need to be confirmed by an administrator
+	maxRangePrefixIPv4 = 24
+	maxRangePrefixIPv6 = 64
+
+	// unconfirmed range bans are discarded after this time
+	pendingRangeBanTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidIPRange is returned if a text is neither an IP, a CIDR network nor an IP range.
+	ErrInvalidIPRange = errors.New("invalid IP range")
+
+	// ErrPendingBanNotFound is returned if a range ban that is to be confirmed does not exist or timed out.
+	ErrPendingBanNotFound = errors.New("no such unconfirmed range ban")
+)
+
+// IPRange is an inclusive range of IP addresses, like the ones that are banned with the ban_range command.
+type IPRange struct {
+	First net.IP
+	Last  net.IP
+}
+
+// normalizeIP returns the 4 byte representation of IPv4 addresses.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// ParseIPRange parses a single IP, a CIDR network like 10.0.0.0/24 or an IP range like 10.0.0.1-10.0.0.20.
+// The range format of the Teeworlds server "10.0.0.1 - 10.0.0.20" is accepted as well.
+func ParseIPRange(text string) (IPRange, error) {
+	text = strings.TrimSpace(text)
+
+	if strings.Contains(text, "/") {
+		ip, network, err := net.ParseCIDR(text)
+		if err != nil {
+			return IPRange{}, ErrInvalidIPRange
+		}
+
+		first := normalizeIP(ip.Mask(network.Mask))
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^network.Mask[i]
+		}
+		return IPRange{First: first, Last: last}, nil
+	}
+
+	parts := strings.Split(text, "-")
+	if len(parts) > 2 {
+		return IPRange{}, ErrInvalidIPRange
+	}
+
+	first := net.ParseIP(strings.TrimSpace(parts[0]))
+	last := net.ParseIP(strings.TrimSpace(parts[len(parts)-1]))
+	if first == nil || last == nil {
+		return IPRange{}, ErrInvalidIPRange
+	}
+
+	first, last = normalizeIP(first), normalizeIP(last)
+	if len(first) != len(last) || bytes.Compare(first, last) > 0 {
+		return IPRange{}, ErrInvalidIPRange
+	}
+	return IPRange{First: first, Last: last}, nil
+}
+
+// Single returns true if the range consists of a single IP.
+func (r IPRange) Single() bool {
+	return r.First.Equal(r.Last)
+}
+
+// Contains returns true if the IP is part of the range.
+func (r IPRange) Contains(ip net.IP) bool {
+	ip = normalizeIP(ip)
+	return len(ip) == len(r.First) && bytes.Compare(r.First, ip) <= 0 && bytes.Compare(ip, r.Last) <= 0
+}
+
+// Size returns the number of IPs within the range.
+func (r IPRange) Size() *big.Int {
+	size := new(big.Int).Sub(new(big.Int).SetBytes(r.Last), new(big.Int).SetBytes(r.First))
+	return size.Add(size, big.NewInt(1))
+}
+
+// Wide returns true if the range contains more IPs than a /24 IPv4 or a /64 IPv6 network.
+func (r IPRange) Wide() bool {
+	hostBits := 32 - maxRangePrefixIPv4
+	if len(r.First) == net.IPv6len {
+		hostBits = 128 - maxRangePrefixIPv6
+	}
+
+	maxSize := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+	return r.Size().Cmp(maxSize) > 0
+}
+
+// String formats the range as "first - last", a single address is formatted without the range.
+// The server quotes both addresses of a range, those are parsed into this form.
+func (r IPRange) String() string {
+	if r.Single() {
+		return r.First.String()
+	}
+	return fmt.Sprintf("%s - %s", r.First, r.Last)
+}
+
+// banCommand returns the econ command that bans either a single IP or an IP range.
+func banCommand(ip string, minutes int, reason string) string {
+	r, err := ParseIPRange(ip)
+	if err == nil && !r.Single() {
+		return fmt.Sprintf("ban_range %s %s %d %s", r.First, r.Last, minutes, reason)
+	}
+	return fmt.Sprintf("ban %s %d %s", ip, minutes, reason)
+}
+
+// unbanCommand returns the econ command that unbans either a single IP or an IP range.
+func unbanCommand(ip string) string {
+	r, err := ParseIPRange(ip)
+	if err == nil && !r.Single() {
+		return fmt.Sprintf("unban_range %s %s", r.First, r.Last)
+	}
+	return fmt.Sprintf("unban %s", ip)
+}
+
+// PendingRangeBan is a wide range ban that waits for the confirmation of an administrator.
+type PendingRangeBan struct {
+	// either banned on all servers or only on the server
+	Global bool
+	Server Address
+
+	Range     IPRange
+	Duration  time.Duration
+	Reason    string
+	Author    string
+	CreatedAt time.Time
+}
+
+// PendingRangeBans keeps the unconfirmed range bans.
+type PendingRangeBans struct {
+	mu     sync.Mutex
+	nextID int
+	bans   map[int]PendingRangeBan
+}
+
+// Add adds an unconfirmed range ban and returns its ID.
+func (p *PendingRangeBans) Add(ban PendingRangeBan) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.bans == nil {
+		p.bans = make(map[int]PendingRangeBan)
+	}
+
+	for id, pending := range p.bans {
+		if time.Since(pending.CreatedAt) > pendingRangeBanTimeout {
+			delete(p.bans, id)
+		}
+	}
+
+	ban.CreatedAt = time.Now()
+	p.nextID++
+	p.bans[p.nextID] = ban
+	return p.nextID
+}
+
+// Confirm removes the unconfirmed range ban and returns it.
+func (p *PendingRangeBans) Confirm(id int) (PendingRangeBan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ban, ok := p.bans[id]
+	if !ok {
+		return PendingRangeBan{}, ErrPendingBanNotFound
+	}
+	delete(p.bans, id)
+
+	if time.Since(ban.CreatedAt) > pendingRangeBanTimeout {
+		return PendingRangeBan{}, ErrPendingBanNotFound
+	}
+	return ban, nil
+}
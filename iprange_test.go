@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseIPRange(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  string
+		size  string
+		wide  bool
+		valid bool
+	}{
+		{"10.0.0.1", "10.0.0.1", "1", false, true},
+		{"10.0.0.17/24", "10.0.0.0 - 10.0.0.255", "256", false, true},
+		{"10.0.0.0/23", "10.0.0.0 - 10.0.1.255", "512", true, true},
+		{"10.0.0.1-10.0.0.20", "10.0.0.1 - 10.0.0.20", "20", false, true},
+		{"10.0.0.1 - 10.0.0.20", "10.0.0.1 - 10.0.0.20", "20", false, true},
+		{"2001:db8::1/64", "2001:db8:: - 2001:db8::ffff:ffff:ffff:ffff", "18446744073709551616", false, true},
+		{"2001:db8::/48", "2001:db8:: - 2001:db8:0:ffff:ffff:ffff:ffff:ffff", "1208925819614629174706176", true, true},
+		{"10.0.0.20-10.0.0.1", "", "", false, false},
+		{"10.0.0.1-2001:db8::1", "", "", false, false},
+		{"nameless tee", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseIPRange(tt.text)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: unexpected error: %v", tt.text, err)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+
+		if r.String() != tt.want || r.Size().String() != tt.size || r.Wide() != tt.wide {
+			t.Errorf("%q: got %s size=%s wide=%t", tt.text, r, r.Size(), r.Wide())
+		}
+	}
+
+	r, _ := ParseIPRange("10.0.0.0/24")
+	if !r.Contains(net.ParseIP("10.0.0.42")) || r.Contains(net.ParseIP("10.0.1.0")) {
+		t.Error("unexpected range containment")
+	}
+}
+
+func Test_banCommand(t *testing.T) {
+	if cmd := banCommand("10.0.0.1", 5, "spam"); cmd != "ban 10.0.0.1 5 spam" {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+
+	if cmd := banCommand("10.0.0.0 - 10.0.0.255", 5, "spam"); cmd != "ban_range 10.0.0.0 10.0.0.255 5 spam" {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+
+	if cmd := unbanCommand("10.0.0.0 - 10.0.0.255"); cmd != "unban_range 10.0.0.0 10.0.0.255" {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+}
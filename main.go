@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -10,90 +12,84 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
 )
 
-var (
-	config = configuration{}
-
-	// context stuff
-	globalCtx, globalCancel = context.WithCancel(context.Background())
-)
+func main() {
+	env, err := godotenv.Read(".env")
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func init() {
-
-	config = configuration{
-		EconPasswords:            make(map[Address]password),
-		ServerStates:             make(map[Address]*Server),
-		ChannelAddress:           newChannelAddressMap(),
-		DiscordModerators:        newUserSet(),
-		SpiedOnPlayers:           newUserSet(),
-		JoinNotify:               newNotifyMap(),
-		DiscordModeratorCommands: newCommandSet(),
-		DiscordCommandQueue:      make(map[Address]chan command),
-		AnnouncemenServers:       make(map[Address]*AnnouncementServer),
-		MentionLimiter:           make(map[Address]*RateLimiter),
+	opts, err := optionsFromEnv(env)
+	if err != nil {
+		log.Fatalf("error: %s", err.Error())
 	}
 
-	env, err := godotenv.Read(".env")
+	bot, err := New(opts)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("error: %s", err.Error())
 	}
+	defer bot.Close()
 
-	discordToken, ok := env["DISCORD_TOKEN"]
+	log.Printf("\n%s", bot.String())
+
+	// Wait here until CTRL-C or other term signal is received.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sc := make(chan os.Signal, 1)
+		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
+		<-sc
+		cancel()
+	}()
+
+	log.Println("Press CTRL-C to exit.")
+	if err := bot.Run(ctx); err != nil {
+		log.Printf("error: %s", err.Error())
+		return
+	}
+
+	log.Println("Shutting down, please wait...")
+}
 
+// optionsFromEnv creates the options of the bot from the variables of the .env file.
+func optionsFromEnv(env map[string]string) (Options, error) {
+	opts := Options{}
+
+	discordToken, ok := env["DISCORD_TOKEN"]
 	if !ok || discordToken == "" {
-		log.Fatal("error: no DISCORD_TOKEN specified")
+		return opts, errors.New("no DISCORD_TOKEN specified")
 	}
-	config.DiscordToken = discordToken
+	opts.DiscordToken = discordToken
 
 	discordAdmin, ok := env["DISCORD_ADMIN"]
 	if !ok || discordAdmin == "" {
-		log.Fatal("error: no DISCORD_ADMIN specified")
+		return opts, errors.New("no DISCORD_ADMIN specified")
 	}
-	config.DiscordAdmin = discordAdmin
-	config.DiscordModerators.Add(discordAdmin)
+	opts.DiscordAdmin = discordAdmin
 
 	econServers, ok := env["ECON_ADDRESSES"]
-
 	if !ok || econServers == "" {
-		log.Fatal("error: no ECON_ADDRESSES specified")
+		return opts, errors.New("no ECON_ADDRESSES specified")
 	}
 
 	econPasswords, ok := env["ECON_PASSWORDS"]
 	if !ok || econPasswords == "" {
-		log.Fatal("error: no ECON_PASSWORDS specified")
+		return opts, errors.New("no ECON_PASSWORDS specified")
 	}
 
 	moderators, ok := env["DISCORD_MODERATORS"]
 	if ok && len(moderators) > 0 {
-		for _, moderator := range strings.Split(env["DISCORD_MODERATORS"], " ") {
-			config.DiscordModerators.Add(moderator)
-		}
+		opts.DiscordModerators = strings.Split(moderators, " ")
 	}
 
 	commands, ok := env["DISCORD_MODERATOR_COMMANDS"]
 	if ok {
-		allowedCommands := strings.Split(commands, " ")
-		for _, cmd := range allowedCommands {
-			config.DiscordModeratorCommands.Add(cmd)
-		}
-	}
-	config.DiscordModeratorCommands.Add("help")
-	config.DiscordModeratorCommands.Add("status")
-	config.DiscordModeratorCommands.Add("bans")
-	config.DiscordModeratorCommands.Add("multiban")
-	config.DiscordModeratorCommands.Add("multiunban")
-	config.DiscordModeratorCommands.Add("notify")
-	config.DiscordModeratorCommands.Add("unnotify")
-	config.DiscordModeratorCommands.Add("whois")
-
-	moderatorRole, ok := env["DISCORD_MODERATOR_ROLE"]
-	if ok && moderatorRole != "" {
-		config.DiscordModeratorRole = moderatorRole
+		opts.ModeratorCommands = strings.Split(commands, " ")
 	}
 
+	opts.DiscordModeratorRole = env["DISCORD_MODERATOR_ROLE"]
+
 	servers := strings.Split(econServers, " ")
 	passwords := strings.Split(econPasswords, " ")
 
@@ -103,35 +99,63 @@ func init() {
 			passwords = append(passwords, passwords[0])
 		}
 	} else if len(passwords) != len(servers) {
-		log.Fatal("ECON_ADDRESSES and ECON_PASSWORDS mismatch")
-	} else if len(servers) == 0 {
-		log.Fatal("No ECON_ADDRESSES and/or ECON_PASSWORDS specified.")
+		return opts, errors.New("ECON_ADDRESSES and ECON_PASSWORDS mismatch")
 	}
 
-	delayString, ok := env["MODERATOR_MENTION_DELAY"]
-	if !ok || delayString == "" {
-		delayString = "5m"
+	// game mod of each server, zCatch by default
+	profiles := []string{defaultParserProfile}
+	if econProfiles, ok := env["ECON_PROFILES"]; ok && econProfiles != "" {
+		profiles = strings.Split(econProfiles, " ")
 	}
 
-	mentionDelay, err := time.ParseDuration(delayString)
+	// fill list with first profile
+	if len(profiles) == 1 && len(servers) > 1 {
+		for i := 1; i < len(servers); i++ {
+			profiles = append(profiles, profiles[0])
+		}
+	} else if len(profiles) != len(servers) {
+		return opts, errors.New("ECON_ADDRESSES and ECON_PROFILES mismatch")
+	}
 
-	if err != nil {
-		mentionDelay = 5 * time.Minute
+	// servers that are moderated via their log file may execute commands via a separate econ endpoint
+	// file:/path/to/server.log=127.0.0.1:9303
+	commandAddresses := make(map[Address]Address)
+	for _, pair := range strings.Fields(env["ECON_COMMAND_ADDRESSES"]) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return opts, fmt.Errorf("invalid ECON_COMMAND_ADDRESSES entry, expected file:/path/to/server.log=IP:Port: %s", pair)
+		}
+
+		addr := Address(kv[0])
+		if _, isFile := addr.LogFile(); !isFile {
+			return opts, fmt.Errorf("ECON_COMMAND_ADDRESSES can only be used with log files: %s", addr)
+		}
+		commandAddresses[addr] = Address(kv[1])
 	}
 
 	for idx, addr := range servers {
-		config.EconPasswords[Address(addr)] = password(passwords[idx])
-
-		srv := NewServer()
-		config.ServerStates[Address(addr)] = srv
+		if _, err := GetParser(profiles[idx]); err != nil {
+			return opts, fmt.Errorf("invalid ECON_PROFILES: %s", err.Error())
+		}
 
-		srv.AddJoinHandler(func(p Player) {
-			config.NicknameTracker.Add(p)
+		opts.Servers = append(opts.Servers, ServerOptions{
+			Address:        Address(addr),
+			Password:       passwords[idx],
+			Profile:        profiles[idx],
+			CommandAddress: commandAddresses[Address(addr)],
 		})
+	}
 
-		config.DiscordCommandQueue[Address(addr)] = make(chan command)
-		config.MentionLimiter[Address(addr)] = NewRateLimiter(mentionDelay)
+	delayString, ok := env["MODERATOR_MENTION_DELAY"]
+	if !ok || delayString == "" {
+		delayString = "5m"
+	}
+
+	mentionDelay, err := time.ParseDuration(delayString)
+	if err != nil {
+		mentionDelay = defaultMentionDelay
 	}
+	opts.MentionDelay = mentionDelay
 
 	logLevel, ok := env["LOG_LEVEL"]
 	if ok && len(logLevel) > 0 {
@@ -139,67 +163,30 @@ func init() {
 		if err != nil {
 			log.Printf("Invalid value for LOG_LEVEL: %s", logLevel)
 		} else {
-			config.LogLevel = level
+			opts.LogLevel = level
 		}
 	}
 
-	f3emoji, ok := env["F3_EMOJI"]
-	if ok && len(f3emoji) > 0 {
-		config.SetF3Emoji(f3emoji)
-	} else if ok {
-		config.SetF3Emoji("🇾")
-		log.Println("Expected emoji has the format F3_EMOJI: <:f3:691397485327024209> -> f3:691397485327024209")
-	} else {
-		config.SetF3Emoji("🇾")
-	}
+	opts.BanIDCommand = env["BANID_REPLACEMENT_COMMAND"]
+	opts.BanIPCommand = env["BANIP_REPLACEMENT_COMMAND"]
 
-	f4emoji, ok := env["F4_EMOJI"]
-	if ok && len(f4emoji) > 0 {
-		config.SetF4Emoji(f4emoji)
-	} else if ok {
-		config.SetF4Emoji("🇳")
-		log.Println("Expected emoji has the format F4_EMOJI: <:f4:691397506461859840> -> f4:691397506461859840")
-	} else {
-		config.SetF4Emoji("🇳")
-	}
-
-	banEmoji, ok := env["BAN_EMOJI"]
-	if ok && len(banEmoji) > 0 {
-		config.SetBanEmoji(banEmoji)
-	} else if ok {
-		config.SetBanEmoji("🔨")
-		log.Println("Expected emoji has the format BAN_EMOJI: <:ban:529812261460508687> -> ban:529812261460508687")
-	} else {
-		config.SetBanEmoji("🔨")
+	banEscalation, ok := env["BAN_ESCALATION"]
+	if ok && banEscalation != "" {
+		escalation, err := ParseBanEscalation(banEscalation)
+		if err != nil {
+			log.Printf("Invalid value for BAN_ESCALATION: %s", err.Error())
+		} else {
+			opts.BanEscalation = escalation
+		}
 	}
 
-	banIDReplaceCmd, ok := env["BANID_REPLACEMENT_COMMAND"]
-	if ok && (strings.Contains(banIDReplaceCmd, "{ID}") || strings.Contains(banIDReplaceCmd, "{id}")) {
-		banIDReplaceCmd = strings.Replace(banIDReplaceCmd, "{ID}", "%d", 1)
-		banIDReplaceCmd = strings.Replace(banIDReplaceCmd, "{id}", "%d", 1)
-		config.BanReplacementIDCommand = banIDReplaceCmd
-	} else {
-		config.BanReplacementIDCommand = "ban %d 5 violation of rules"
-	}
+	opts.AppealsChannel = env["APPEALS_CHANNEL"]
 
-	banIPReplaceCmd, ok := env["BANIP_REPLACEMENT_COMMAND"]
-	if ok && (strings.Contains(banIPReplaceCmd, "{IP}") || strings.Contains(banIPReplaceCmd, "{ip}")) {
-		banIPReplaceCmd = strings.Replace(banIPReplaceCmd, "{IP}", "%s", 1)
-		banIPReplaceCmd = strings.Replace(banIPReplaceCmd, "{ip}", "%s", 1)
-		config.BanReplacementIPCommand = banIPReplaceCmd
-	} else {
-		config.BanReplacementIDCommand = "ban %d 10 violation of rules"
-	}
-
-	unbanEmoji, ok := env["UNBAN_EMOJI"]
-	if ok && len(unbanEmoji) > 0 {
-		config.SetUnbanEmoji(unbanEmoji)
-	} else if ok {
-		config.SetUnbanEmoji("❎")
-		log.Println("Expected emoji has the format UNBAN_EMOJI: <:sendhelp:529812377441402881> -> sendhelp:529812377441402881")
-	} else {
-		config.SetUnbanEmoji("❎")
+	stateFile, ok := env["STATE_FILE"]
+	if !ok || stateFile == "" {
+		stateFile = "state.json"
 	}
+	opts.StateFile = stateFile
 
 	trackNicks := env["NICKNAME_TRACKING"]
 	areNicksTracked := false
@@ -233,91 +220,36 @@ func init() {
 			log.Println(err)
 		}
 
-		config.NicknameTracker = tracker
-
+		opts.NicknameTracker = tracker
 	}
 
-	log.Printf("\n%s", config.String())
-}
-
-func main() {
-	defer config.Close()
-
-	dg, err := discordgo.New("Bot " + config.DiscordToken)
-	if err != nil {
-		log.Println(err)
-		return
+	banHistoryFile, ok := env["BAN_HISTORY_FILE"]
+	if !ok {
+		banHistoryFile = "bans.db"
 	}
 
-	dg.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
-		if m.Author.ID == s.State.User.ID {
-			return
+	// empty value disables the ban history
+	if banHistoryFile != "" {
+		history, err := NewBanHistory(banHistoryFile)
+		if err != nil {
+			log.Println(err)
 		}
 
-		// author stays the same
-		author := m.Author.String()
-
-		// each new line might contain a command
-		lines := strings.Split(m.Content, "\n")
-
-		// try to execute each command
-		for _, line := range lines {
-
-			prefix := ""
-			command := ""
-			args := ""
-
-			if len(line) >= 1 {
-				prefix = line[:1]
-			} else {
-				continue
-			}
-
-			if len(line) >= 2 {
-				strs := strings.SplitN(line[1:], " ", 2)
-
-				if len(strs) >= 1 {
-					command = strs[0]
-				}
-
-				if len(strs) == 2 {
-					args = strs[1]
-				}
-			}
-
-			switch prefix {
-			case "?":
-				if !config.DiscordModerators.Contains(author) {
-					s.ChannelMessageSend(m.ChannelID, "no access to moderator commands.")
-					continue
-				}
-				ModeratorCommandsHandler(s, m, author, command, args)
-			case "#":
-				if author != config.DiscordAdmin {
-					s.ChannelMessageSend(m.ChannelID, "no access to admin commands.")
-					continue
-				}
-				AdminCommandsHandler(s, m, author, command, args)
-			default:
-				continue
-			}
+		opts.BanHistory = history
+	}
 
-		}
-	})
+	globalBansFile, ok := env["GLOBAL_BANS_FILE"]
+	if !ok {
+		globalBansFile = "globalbans.json"
+	}
 
-	err = dg.Open()
+	// empty value keeps the global bans in memory
+	globalBans, err := NewGlobalBanList(globalBansFile)
 	if err != nil {
-		log.Println("error: could not establish a connection to the discord api, please check your credentials")
-		return
+		log.Printf("error while loading the global bans from %s: %s", globalBansFile, err.Error())
+		globalBans, _ = NewGlobalBanList("")
 	}
-	defer dg.Close()
-
-	// Wait here until CTRL-C or other term signal is received.
-	log.Println("Bot is now running.  Press CTRL-C to exit.")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
-	<-sc
-	globalCancel()
+	opts.GlobalBans = globalBans
 
-	log.Println("Shutting down, please wait...")
+	return opts, nil
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"net"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -67,6 +69,23 @@ func (n *NicknameTracker) IPs(name string) ([]string, error) {
 	return ips, nil
 }
 
+// Nicknames returns a list of nicknames a specific IP has been seen with.
+func (n *NicknameTracker) Nicknames(ip string) ([]string, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	nicknames, err := n.SMembers(ip).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byName(nicknames))
+
+	return nicknames, nil
+}
+
 // WhoIs return all associated nicknames with specific IPs
 func (n *NicknameTracker) WhoIs(name string) ([]string, error) {
 	if n == nil {
@@ -116,3 +135,50 @@ func (n *NicknameTracker) WhoIs(name string) ([]string, error) {
 
 	return result, nil
 }
+
+const (
+	// the search is used by the autocompletion, which must respond within three seconds
+	nicknameSearchTimeout = time.Second
+	nicknameSearchScans   = 50
+	nicknameSearchCount   = 1000
+)
+
+// redisGlobReplacer escapes the special characters of redis' MATCH patterns.
+var redisGlobReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Search returns up to limit known nicknames that start with the passed prefix.
+// The keyspace is scanned for at most a second, large databases might not be searched completely.
+func (n *NicknameTracker) Search(prefix string, limit int) ([]string, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	pattern := redisGlobReplacer.Replace(prefix) + "*"
+	nicknames := make([]string, 0, limit)
+
+	deadline := time.Now().Add(nicknameSearchTimeout)
+
+	var cursor uint64
+	for scans := 0; scans < nicknameSearchScans && time.Now().Before(deadline); scans++ {
+		keys, next, err := n.Scan(cursor, pattern, nicknameSearchCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// IPs and nicknames share the same keys
+		for _, key := range keys {
+			if net.ParseIP(key) == nil && len(nicknames) < limit {
+				nicknames = append(nicknames, key)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 || len(nicknames) >= limit {
+			break
+		}
+	}
+
+	sort.Sort(byName(nicknames))
+
+	return nicknames, nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultParserProfile = "zcatch"
+
+var (
+	// ErrUnknownParserProfile is returned if a server is configured with a profile that is not registered.
+	ErrUnknownParserProfile = errors.New("unknown parser profile")
+
+	// the profiles are registered during the variable initialization,
+	// as the options of a Bot may reference them at any time.
+	parsersMu sync.RWMutex
+	parsers   = map[string]LineParser{
+		zcatchProfile.Name:    zcatchProfile,
+		vanilla06Profile.Name: vanilla06Profile,
+		vanilla07Profile.Name: vanilla07Profile,
+		ddnetProfile.Name:     ddnetProfile,
+	}
+)
+
+// LineParser parses the log lines of a game mod, updates the server state and returns the resulting events.
+type LineParser interface {
+	// Parse parses a single line of the external console, nil is returned if the line does not need to be shown.
+	Parse(line string, server *Server) Event
+}
+
+// RegisterParser adds a parser to the profiles that servers can be configured with.
+func RegisterParser(profile string, parser LineParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+
+	parsers[profile] = parser
+}
+
+// GetParser returns the parser of a registered profile.
+func GetParser(profile string) (LineParser, error) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	parser, ok := parsers[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s', expected one of: %s", ErrUnknownParserProfile, profile, strings.Join(parserProfiles(), ", "))
+	}
+	return parser, nil
+}
+
+// parserProfiles must be called while holding the lock.
+func parserProfiles() []string {
+	profiles := make([]string, 0, len(parsers))
+	for profile := range parsers {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// lineKind tells what a matched log line means.
+type lineKind int
+
+// the named groups of each kind's regex are documented next to it
+const (
+	// id|xid, ip, port and optionally name, clan, country, version|xversion
+	lineJoin lineKind = iota
+	// id, name: the name of a player that joined without one
+	lineName
+	// id and optionally reason
+	lineLeave
+	// same as lineJoin, response of the status command
+	lineStatus
+	// none, the end of the status command's response
+	lineSyncMarker
+	// value: seconds, the response of the sv_vote_time command
+	lineVoteTime
+	// none, the end of the sv_vote_time command's response
+	lineVoteTimeSyncMarker
+
+	// voter_id, voter_name, target_id, target_name, reason, cmd, force
+	lineVoteKick
+	lineVoteSpectate
+	// voter_id, voter_name, option, reason, cmd, force
+	lineVoteOption
+	// none
+	lineVoteForcedYes
+	lineVoteForcedNo
+	// result: passed, failed or aborted
+	lineVoteEnded
+
+	// id, rank
+	lineRconAuth
+	// id, cmd
+	lineRconCommand
+
+	// id, name, text
+	lineChat
+	lineTeamChat
+	lineWhisper
+
+	// ip, minutes, reason: minutes are empty for permanent bans, last is the end of a range
+	lineBan
+	lineBanListEntry
+	// none
+	lineBanListEnd
+	// ip and optionally last
+	lineBanExpired
+	lineUnban
+	// none
+	lineUnbanAll
+	// message
+	lineBanError
+
+	// line: shown without being parsed
+	lineServer
+)
+
+// lineRule matches the log lines of the passed categories with a regex.
+type lineRule struct {
+	Kind       lineKind
+	Categories []string
+	Regex      *regexp.Regexp
+}
+
+// match returns the named groups of the regex or false, if the line does not match.
+func (r *lineRule) match(category, logLine string) (map[string]string, bool) {
+	found := false
+	for _, c := range r.Categories {
+		if c == category {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	return namedGroups(r.Regex, logLine)
+}
+
+// namedGroups returns the named groups of the regex or false, if the text does not match.
+func namedGroups(regex *regexp.Regexp, text string) (map[string]string, bool) {
+	matches := regex.FindStringSubmatch(text)
+	if matches == nil {
+		return nil, false
+	}
+
+	groups := make(map[string]string, len(matches))
+	for idx, name := range regex.SubexpNames() {
+		if name != "" {
+			groups[name] = matches[idx]
+		}
+	}
+	return groups, true
+}
+
+// LogProfile is a line parser that is described by the log format of a game mod.
+type LogProfile struct {
+	Name string
+
+	// named groups: category, line
+	LineFormats []*regexp.Regexp
+
+	// the first matching rule is applied
+	Rules []lineRule
+}
+
+// Parse splits the line into its log category and the log line and applies the rules to it.
+func (p *LogProfile) Parse(line string, server *Server) Event {
+	for _, format := range p.LineFormats {
+		groups, ok := namedGroups(format, line)
+		if !ok {
+			continue
+		}
+
+		_, event := p.ParseLine(groups["category"], groups["line"], server)
+		return event
+	}
+	return nil
+}
+
+// ParseLine applies the first matching rule to a log line of the passed category.
+func (p *LogProfile) ParseLine(category, logLine string, server *Server) (consumed bool, event Event) {
+	for _, rule := range p.Rules {
+		groups, ok := rule.match(category, logLine)
+		if ok {
+			return true, applyRule(rule.Kind, groups, logLine, server)
+		}
+	}
+	return false, nil
+}
+
+// intGroup parses a numeric group, a group with an x prefix is hexadecimal.
+func intGroup(groups map[string]string, name string) (int, bool) {
+	if value, ok := groups[name]; ok {
+		i, err := strconv.Atoi(value)
+		return i, err == nil
+	}
+
+	if value, ok := groups["x"+name]; ok {
+		i, err := strconv.ParseInt(strings.TrimPrefix(value, "0x"), 16, 64)
+		return int(i), err == nil
+	}
+	return 0, false
+}
+
+// addressGroup returns the banned address of a net_ban line,
+// ranges are formatted the same way as IPRange.String.
+func addressGroup(groups map[string]string) string {
+	ip, last := groups["ip"], groups["last"]
+	if last == "" {
+		return ip
+	}
+
+	r, err := ParseIPRange(ip + "-" + last)
+	if err != nil {
+		return fmt.Sprintf("%s - %s", ip, last)
+	}
+	return r.String()
+}
+
+// playerGroups creates a player from the named groups of a join or status line.
+func playerGroups(groups map[string]string) Player {
+	id, _ := intGroup(groups, "id")
+	port, _ := intGroup(groups, "port")
+	version, _ := intGroup(groups, "version")
+
+	country, ok := intGroup(groups, "country")
+	if !ok {
+		country = -1
+	}
+
+	return Player{
+		ID:      id,
+		Name:    groups["name"],
+		Clan:    groups["clan"],
+		Country: country,
+		IP:      groups["ip"],
+		Port:    port,
+		Version: version,
+	}
+}
+
+// applyRule updates the server state and creates the event of a matched line.
+func applyRule(kind lineKind, groups map[string]string, logLine string, server *Server) Event {
+	id, _ := intGroup(groups, "id")
+	minutes, _ := intGroup(groups, "minutes")
+
+	switch kind {
+	case lineJoin:
+		return server.PlayerEntered(playerGroups(groups))
+	case lineName:
+		return server.PlayerNamed(id, groups["name"])
+	case lineLeave:
+		return server.PlayerLeft(id, groups["reason"])
+	case lineStatus:
+		// the bot requested the status, don't show it in the channel
+		if server.UpdateStatus(playerGroups(groups)) {
+			return nil
+		}
+		return ServerEvent{Line: logLine}
+	case lineSyncMarker:
+		server.EndSync()
+		return nil
+	case lineVoteTime:
+		seconds, _ := intGroup(groups, "value")
+		server.Votes.SyncTime(seconds)
+		return nil
+	case lineVoteTimeSyncMarker:
+		server.Votes.EndTimeSync()
+		return nil
+
+	case lineVoteKick, lineVoteSpectate, lineVoteOption:
+		voterID, _ := intGroup(groups, "voter_id")
+		targetID, _ := intGroup(groups, "target_id")
+
+		e := VoteStartEvent{
+			Type:    VoteKick,
+			Voter:   votePlayer(server, voterID, groups["voter_name"]),
+			Reason:  groups["reason"],
+			Command: groups["cmd"],
+			Forced:  groups["force"] == "1",
+		}
+
+		switch kind {
+		case lineVoteOption:
+			e.Type = VoteOption
+			e.Option = groups["option"]
+			return server.VoteStarted(e)
+		case lineVoteSpectate:
+			e.Type = VoteSpectate
+		}
+		e.Target = votePlayer(server, targetID, groups["target_name"])
+		return server.VoteStarted(e)
+	case lineVoteForcedYes:
+		return server.VoteForced(true)
+	case lineVoteForcedNo:
+		return server.VoteForced(false)
+	case lineVoteEnded:
+		return server.VoteEnded(VoteOutcome(groups["result"]))
+
+	case lineRconAuth:
+		return RconAuthEvent{
+			Player: server.Player(id),
+			Rank:   groups["rank"],
+		}
+	case lineRconCommand:
+		server.Votes.TimeCommand(groups["cmd"])
+		return RconCommandEvent{
+			Player:  server.Player(id),
+			Command: groups["cmd"],
+		}
+
+	case lineChat, lineTeamChat, lineWhisper:
+		chatType := ChatAll
+		if kind == lineTeamChat {
+			chatType = ChatTeam
+		} else if kind == lineWhisper {
+			chatType = ChatWhisper
+		}
+
+		name, text := groups["name"], groups["text"]
+		if known := server.Player(id).Name; known != "" && strings.HasPrefix(groups["message"], known+": ") {
+			name, text = known, strings.TrimPrefix(groups["message"], known+": ")
+		}
+
+		return ChatEvent{
+			Type: chatType,
+			ID:   id,
+			Name: name,
+			Text: text,
+		}
+
+	case lineBan:
+		return server.AddBan(addressGroup(groups), minutes, groups["reason"])
+	case lineBanListEntry:
+		server.AddBanListEntry(addressGroup(groups), minutes, groups["reason"])
+		return nil
+	case lineBanListEnd:
+		server.EndBanList()
+		return nil
+	case lineBanExpired:
+		return server.RemoveBan(BanEventExpire, addressGroup(groups))
+	case lineUnban:
+		return server.RemoveBan(BanEventUnban, addressGroup(groups))
+	case lineUnbanAll:
+		return server.RemoveAllBans()
+	case lineBanError:
+		return ErrorEvent{Message: groups["message"]}
+
+	case lineServer:
+		return ServerEvent{Line: logLine}
+	}
+	return nil
+}
+
+// votePlayer returns the online player that is part of a vote, the name of the log line is kept.
+func votePlayer(server *Server, id int, name string) Player {
+	p := server.Player(id)
+	p.ID = id
+	p.Name = name
+	return p
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestZCatchProfile(t *testing.T) {
+	s := NewServer()
+	zcatchProfile.ParseLine("client_enter", "id=3 addr=192.168.178.25:64139 version=1796 name='voter' clan='' country=-1", s)
+	zcatchProfile.ParseLine("client_enter", "id=5 addr=192.168.178.26:64140 version=1796 name='voted' clan='' country=-1", s)
+
+	event := zcatchProfile.Parse("[2020-05-22 23:01:09][server]: '3:voter' voted kick '5:voted' reason='spam' cmd='ban 5 5 spam' force=0", s)
+	vote, ok := event.(VoteStartEvent)
+	if !ok {
+		t.Fatalf("expected a vote, got: %#v", event)
+	}
+	if vote.Type != VoteKick || vote.Voter.IP != "192.168.178.25" || vote.Target.ID != 5 || vote.Target.Name != "voted" || vote.Forced {
+		t.Errorf("unexpected vote: %#v", vote)
+	}
+	if want := "**[kickvote]**: 3:'voter' started to kick 5:'voted' with reason 'spam'"; vote.Render() != want {
+		t.Errorf("expected %q, got %q", want, vote.Render())
+	}
+
+	event = zcatchProfile.Parse("[server]: '3:voter' voted option 'restart' reason='bug' cmd='restart' force=1", s)
+	if vote, ok := event.(VoteStartEvent); !ok || vote.Type != VoteOption || vote.Option != "restart" || !vote.Forced {
+		t.Errorf("unexpected option vote: %#v", event)
+	}
+
+	event = zcatchProfile.Parse("[chat]: 3:0:voter: hello world", s)
+	if chat, ok := event.(ChatEvent); !ok || chat.Type != ChatAll || chat.ID != 3 || chat.Text != "hello world" {
+		t.Errorf("unexpected chat message: %#v", event)
+	} else if want := "[chat]: 3:'voter': hello world"; chat.Render() != want {
+		t.Errorf("expected %q, got %q", want, chat.Render())
+	}
+
+	event = zcatchProfile.Parse("[server]: ClientID=3 authed (admin)", s)
+	if auth, ok := event.(RconAuthEvent); !ok || auth.Player.Name != "voter" || auth.Rank != "admin" {
+		t.Errorf("unexpected rcon login: %#v", event)
+	}
+
+	event = zcatchProfile.Parse("[net_ban]: banned '192.168.178.26' for 5 minutes (spam)", s)
+	ban, ok := event.(BanEvent)
+	if !ok || ban.Type != BanEventBan || ban.Player.Name != "voted" {
+		t.Fatalf("unexpected ban: %#v", event)
+	}
+
+	event = zcatchProfile.Parse("[net_ban]: unbanned '192.168.178.26'", s)
+	if unban, ok := event.(BanEvent); !ok || unban.Type != BanEventUnban || unban.Reason != "spam" {
+		t.Errorf("unexpected unban: %#v", event)
+	} else if want := "[bans]: unbanned 'voted' (spam)"; unban.Render() != want {
+		t.Errorf("expected %q, got %q", want, unban.Render())
+	}
+
+	if event = zcatchProfile.Parse("not a log line", s); event != nil {
+		t.Errorf("expected no event, got: %#v", event)
+	}
+}
+
+func TestGetParser(t *testing.T) {
+	for _, profile := range []string{"zcatch", "vanilla06", "vanilla07", "ddnet"} {
+		if _, err := GetParser(profile); err != nil {
+			t.Errorf("profile %s should be registered: %s", profile, err)
+		}
+	}
+
+	if _, err := GetParser("infclass"); !errors.Is(err, ErrUnknownParserProfile) {
+		t.Errorf("expected %v, got %v", ErrUnknownParserProfile, err)
+	}
+}
+
+func TestProfiles_JoinChatLeave(t *testing.T) {
+	tests := []struct {
+		profile *LogProfile
+		lines   []string
+	}{
+		{vanilla06Profile, []string{
+			"[5ec84d55][server]: player has entered the game. ClientID=a addr=192.168.178.25:64139",
+			"[5ec84d55][game]: team_join player='10:nameless tee' team=0",
+			"[5ec84d55][chat]: 10:-2:nameless tee: hello world",
+			"[5ec84d55][server]: client dropped. cid=10 addr=192.168.178.25:64139 reason=''",
+		}},
+		{vanilla07Profile, []string{
+			"[2020-05-22 23:01:09][server]: player has entered the game. ClientID=10 addr=192.168.178.25:64139",
+			"[2020-05-22 23:01:09][game]: team_join player='10:nameless tee' team=0",
+			"[2020-05-22 23:01:10][chat]: 10:0:nameless tee: hello world",
+			"[2020-05-22 23:01:11][server]: client dropped. cid=10 addr=192.168.178.25:64139 reason=''",
+		}},
+		{ddnetProfile, []string{
+			"2023-01-15 20:01:09 I server: player has entered the game. ClientID=10 addr=<{192.168.178.25:64139}> sixup=0",
+			"2023-01-15 20:01:09 I game: team_join player='10:nameless tee' team=0",
+			"2023-01-15 20:01:10 I chat: 10:-2:nameless tee: hello world",
+			"2023-01-15 20:01:11 I server: client dropped. cid=10 addr=<{192.168.178.25:64139}> reason=''",
+		}},
+	}
+
+	for _, tt := range tests {
+		s := NewServer()
+
+		if event := tt.profile.Parse(tt.lines[0], s); event != nil {
+			t.Errorf("%s: players without a name should not be announced, got: %#v", tt.profile.Name, event)
+		}
+
+		event := tt.profile.Parse(tt.lines[1], s)
+		if join, ok := event.(JoinEvent); !ok || join.Player.ID != 10 || join.Player.Name != "nameless tee" || join.Player.IP != "192.168.178.25" {
+			t.Errorf("%s: unexpected join: %#v", tt.profile.Name, event)
+		}
+
+		if event := tt.profile.Parse(tt.lines[1], s); event != nil {
+			t.Errorf("%s: changing the team should not announce the player again, got: %#v", tt.profile.Name, event)
+		}
+
+		event = tt.profile.Parse(tt.lines[2], s)
+		if chat, ok := event.(ChatEvent); !ok || chat.ID != 10 || chat.Text != "hello world" {
+			t.Errorf("%s: unexpected chat message: %#v", tt.profile.Name, event)
+		}
+
+		event = tt.profile.Parse(tt.lines[3], s)
+		if leave, ok := event.(LeaveEvent); !ok || leave.Player.Name != "nameless tee" {
+			t.Errorf("%s: unexpected leave: %#v", tt.profile.Name, event)
+		}
+
+		if len(s.Status()) != 0 {
+			t.Errorf("%s: player slot should have been cleared", tt.profile.Name)
+		}
+	}
+}
+
+// renderGolden formats the event of a log line as a single line of a golden file.
+func renderGolden(event Event) string {
+	if event == nil {
+		return "<nil>"
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", event), "main.")
+	return name + ": " + strings.ReplaceAll(event.Render(), "\n", `\n`)
+}
+
+func TestZCatchProfile_Golden(t *testing.T) {
+	input, err := os.Open(filepath.Join("testdata", "zcatch.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+
+	s := NewServer()
+
+	var sb strings.Builder
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		sb.WriteString(renderGolden(zcatchProfile.Parse(scanner.Text(), s)))
+		sb.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "zcatch.golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, []byte(sb.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run go test -run Golden -update to create the golden file", err)
+	}
+
+	actualLines := strings.Split(sb.String(), "\n")
+	expectedLines := strings.Split(string(expected), "\n")
+	if len(actualLines) != len(expectedLines) {
+		t.Fatalf("expected %d lines, got %d", len(expectedLines), len(actualLines))
+	}
+	for idx := range expectedLines {
+		if actualLines[idx] != expectedLines[idx] {
+			t.Errorf("line %d: expected %q, got %q", idx+1, expectedLines[idx], actualLines[idx])
+		}
+	}
+}
+
+// fuzzSeeds returns the lines of the golden corpus.
+func fuzzSeeds(f *testing.F) []string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "zcatch.log"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func FuzzLogProfile_Parse(f *testing.F) {
+	for _, line := range fuzzSeeds(f) {
+		f.Add(line)
+	}
+
+	profiles := []*LogProfile{zcatchProfile, vanilla06Profile, vanilla07Profile, ddnetProfile}
+	f.Fuzz(func(t *testing.T, line string) {
+		for _, profile := range profiles {
+			// parsing must neither panic nor depend on anything but the line and the server state
+			first := renderGolden(profile.Parse(line, NewServer()))
+			second := renderGolden(profile.Parse(line, NewServer()))
+			if first != second {
+				t.Errorf("%s: unstable result for %q: %q and %q", profile.Name, line, first, second)
+			}
+		}
+	})
+}
+
+func FuzzLogProfile_ParseLine(f *testing.F) {
+	f.Add("client_enter", "id=64 addr=192.168.178.25:64139 version=1796 name='nameless tee' clan='' country=-1")
+	f.Add("client_drop", "id=99 addr=192.168.178.25 reason=''")
+	f.Add("game", "team_join player='64:nameless tee' team=0")
+	f.Add("server", "player has entered the game. ClientID=ff addr=192.168.178.25:64139")
+	f.Add("server", "ClientID=99 authed (admin)")
+	f.Add("chat", "64:0:nameless tee: hi")
+	f.Add("net_ban", "#0 '192.168.178.25' banned for 5 minutes (spam)")
+	f.Add("Server", "id=64 addr=192.168.178.25:64139 name='nameless tee' score=0")
+
+	profiles := []*LogProfile{zcatchProfile, vanilla06Profile, vanilla07Profile, ddnetProfile}
+	f.Fuzz(func(t *testing.T, category, line string) {
+		for _, profile := range profiles {
+			s := NewServer()
+			s.BeginSync()
+			_, event := profile.ParseLine(category, line, s)
+			renderGolden(event)
+			s.EndSync()
+		}
+	})
+}
+
+// validName returns true for names that the Teeworlds server allows, at most 15 bytes of UTF-8.
+func validName(name string, maxLength int) bool {
+	return len(name) <= maxLength && utf8.ValidString(name) && !strings.ContainsAny(name, "\r\n\x00")
+}
+
+func FuzzZCatchProfile_JoinRoundTrip(f *testing.F) {
+	f.Add(0, "nameless tee", "", -1)
+	f.Add(63, "it's me", "[x]", 276)
+	f.Add(5, "fifteen chars!!", "elevenchars", 40)
+
+	f.Fuzz(func(t *testing.T, id int, name, clan string, country int) {
+		if id < 0 || maxPlayers <= id || name == "" || !validName(name, 15) || !validName(clan, 11) || strings.Contains(name, "' clan='") {
+			t.Skip()
+		}
+
+		expected := Player{ID: id, Name: name, Clan: clan, Country: country, IP: "192.168.178.25", Port: 64139, Version: 1796}
+		line := fmt.Sprintf("[client_enter]: id=%d addr=%s:%d version=%d name='%s' clan='%s' country=%d",
+			id, expected.IP, expected.Port, expected.Version, name, clan, country)
+
+		s := NewServer()
+		join, ok := zcatchProfile.Parse(line, s).(JoinEvent)
+		if !ok || join.Player != expected {
+			t.Fatalf("%q: expected %+v, got %+v", line, expected, join.Player)
+		}
+		if p := s.Player(id); p != expected {
+			t.Errorf("%q: expected the slot to contain %+v, got %+v", line, expected, p)
+		}
+	})
+}
+
+func FuzzZCatchProfile_ChatRoundTrip(f *testing.F) {
+	f.Add(0, "nameless tee", "gg")
+	f.Add(3, "a: b", "c: d")
+	f.Add(1, "MisterFister:(", ":(")
+	f.Add(2, "it's me", "[2020-05-22 23:01:09][net_ban]: unbanned all entries")
+
+	f.Fuzz(func(t *testing.T, id int, name, text string) {
+		if id < 0 || maxPlayers <= id || name == "" || !validName(name, 15) || strings.Contains(name, "' clan='") || strings.ContainsAny(text, "\n") {
+			t.Skip()
+		}
+
+		s := NewServer()
+		zcatchProfile.Parse(fmt.Sprintf("[client_enter]: id=%d addr=192.168.178.25:64139 version=1796 name='%s' clan='' country=-1", id, name), s)
+
+		line := fmt.Sprintf("[chat]: %d:0:%s: %s", id, name, text)
+		chat, ok := zcatchProfile.Parse(line, s).(ChatEvent)
+		if !ok || chat.ID != id || chat.Name != name || chat.Text != text {
+			t.Fatalf("%q: expected %d:%q: %q, got %+v", line, id, name, text, chat)
+		}
+	})
+}
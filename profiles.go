@@ -0,0 +1,181 @@
+package main
+
+import (
+	"regexp"
+)
+
+// the server quotes banned addresses, ranges are printed as '192.168.178.0' - '192.168.178.255'
+const netBanAddress = `'(?P<ip>[^']+)'(?: - '(?P<last>[^']+)')?`
+
+var (
+	// [2020-05-22 23:01:09][client_enter]: id=0 addr=192.168.178.25:64139 version=1796 name='MisterFister:(' clan='FistingTea`' country=-1
+	// the formats are anchored, players must not be able to inject log lines via the chat
+	timestampLineFormat = regexp.MustCompile(`^\[(?P<time>[\d -:]+)\]\[(?P<category>[^:]+)\]: (?P<line>.+)$`)
+
+	// no timestamp
+	// [client_enter]: id=0 addr=192.168.178.25:64139 version=1796 name='MisterFister:(' clan='FistingTea`' country=-1
+	plainLineFormat = regexp.MustCompile(`^\[(?P<category>[^:]+)\]: (?P<line>.+)$`)
+
+	// hexadecimal timestamp of 0.6 servers
+	// [5ec84d55][server]: player has entered the game. ClientID=0 addr=192.168.178.25:64139
+	hexTimestampLineFormat = regexp.MustCompile(`^\[(?P<time>[0-9a-fA-F]+)\]\[(?P<category>[^\]]+)\]: (?P<line>.+)$`)
+
+	// log format of recent DDNet versions
+	// 2023-01-15 20:01:09 I server: player has entered the game. ClientID=0 addr=<{192.168.178.25:64139}> sixup=0
+	ddnetLineFormat = regexp.MustCompile(`^(?P<time>[\d-]+ [\d:]+) [A-Z] (?P<category>[^:]+): (?P<line>.+)$`)
+
+	// response of the status command of 0.7 servers
+	// [Server]: id=0 addr=192.168.178.25:64139 client=0x0704 secure=yes name='nameless tee' clan='' country=-1
+	status07Rule = lineRule{lineStatus, []string{"Server", "server"}, regexp.MustCompile(`^id=(?P<id>[\d]+) addr=(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+) client=(?P<xversion>(?:0x)?[a-fA-F0-9]+) secure=(?:yes|no) name='(?P<name>.*)' clan='(?P<clan>.*)' country=(?P<country>[-\d]+)`)}
+	// response of the status command of 0.6 servers
+	// [Server]: id=0 addr=192.168.178.25:64139 name='nameless tee' score=0
+	status06Rule = lineRule{lineStatus, []string{"Server", "server"}, regexp.MustCompile(`^id=(?P<id>[\d]+) addr=(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+) name='(?P<name>.*)' score=[-\d]+`)}
+
+	// echoed by the bot after the status command
+	syncMarkerRule = lineRule{lineSyncMarker, []string{"Console"}, regexp.MustCompile(`^` + regexp.QuoteMeta(statusSyncMarker) + `$`)}
+
+	// response of the sv_vote_time command, followed by the echoed marker
+	// [Console]: Value: 25
+	voteTimeRules = []lineRule{
+		{lineVoteTime, []string{"Console", "console", "config"}, regexp.MustCompile(`^Value: (?P<value>[\d]+)$`)},
+		{lineVoteTimeSyncMarker, []string{"Console"}, regexp.MustCompile(`^` + regexp.QuoteMeta(voteTimeSyncMarker) + `$`)},
+	}
+
+	// votes, forced votes and the remote console are logged the same way by all game mods
+	voteRules = []lineRule{
+		{lineVoteOption, []string{"server", "vote"}, regexp.MustCompile(`'(?P<voter_id>[\d]{1,2}):(?P<voter_name>.*)' voted option '(?P<option>.+)' reason='(?P<reason>.{1,20})' cmd='(?P<cmd>.+)' force=(?P<force>[\d])`)},
+		{lineVoteKick, []string{"server", "vote"}, regexp.MustCompile(`'(?P<voter_id>[\d]{1,2}):(?P<voter_name>.*)' voted kick '(?P<target_id>[\d]{1,2}):(?P<target_name>.*)' reason='(?P<reason>.{1,20})' cmd='(?P<cmd>.*)' force=(?P<force>[\d])`)},
+		{lineVoteSpectate, []string{"server", "vote"}, regexp.MustCompile(`'(?P<voter_id>[\d]{1,2}):(?P<voter_name>.*)' voted spectate '(?P<target_id>[\d]{1,2}):(?P<target_name>.*)' reason='(?P<reason>.{1,20})' cmd='(?P<cmd>.*)' force=(?P<force>[\d])`)},
+		{lineVoteForcedYes, []string{"server", "vote"}, regexp.MustCompile(`forcing vote yes$`)},
+		{lineVoteForcedNo, []string{"server", "vote"}, regexp.MustCompile(`forcing vote no$`)},
+
+		// the outcome is announced in the chat by the server, either with or without the ID prefix
+		// [chat]: -1:-1:*** Vote passed
+		{lineVoteEnded, []string{"chat"}, regexp.MustCompile(`^(?:-1:-1:)?\*\*\* Vote (?P<result>passed|failed|aborted)`)},
+	}
+
+	rconRules = []lineRule{
+		{lineRconAuth, []string{"server"}, regexp.MustCompile(`ClientID=(?P<id>\d+) authed (?:with key=.* )?\((?P<rank>.*)\)`)},
+		{lineRconCommand, []string{"server"}, regexp.MustCompile(`ClientID=(?P<id>\d+) rcon='(?P<cmd>.*)'$`)},
+	}
+
+	// chat messages are logged as: <id>:<team or mode>:<name>: <text>
+	// names may contain ": " as well, which is resolved with the name of the online player.
+	chatRules = []lineRule{
+		{lineChat, []string{"chat"}, regexp.MustCompile(`^(?P<id>[-\d]+):[-\d]+:(?P<message>(?P<name>.{1,16}): (?P<text>.*))$`)},
+		{lineTeamChat, []string{"teamchat"}, regexp.MustCompile(`^(?P<id>[-\d]+):[-\d]+:(?P<message>(?P<name>.{1,16}): (?P<text>.*))$`)},
+		{lineWhisper, []string{"whisper"}, regexp.MustCompile(`^(?P<id>[-\d]+):[-\d]+:(?P<message>(?P<name>.{1,16}): (?P<text>.*))$`)},
+	}
+
+	// the bans are handled by the network code, which is the same for all game mods
+	netBanRules = []lineRule{
+		// [net_ban]: banned '192.168.178.0' - '192.168.178.255' for 10 minutes (bots)
+		{lineBan, []string{"net_ban"}, regexp.MustCompile(`^banned ` + netBanAddress + ` for (?:(?P<minutes>[\d]+) minute[s]?|life) \((?P<reason>.*)\)$`)},
+		{lineBan, []string{"net_ban"}, regexp.MustCompile(`^` + netBanAddress + ` banned for (?:(?P<minutes>[\d]+) minute[s]?|life) \((?P<reason>.*)\)$`)},
+
+		// response of the bans command
+		// [net_ban]: #0 '192.168.178.25' banned for 5 minutes (spam)
+		{lineBanListEntry, []string{"net_ban"}, regexp.MustCompile(`^#(?P<index>[\d]+) ` + netBanAddress + ` banned for (?:(?P<minutes>[\d]+) minute[s]?|life) \((?P<reason>.*)\)$`)},
+		// [net_ban]: 3 bans
+		{lineBanListEnd, []string{"net_ban"}, regexp.MustCompile(`^[\d]+ bans?$`)},
+
+		{lineBanExpired, []string{"net_ban"}, regexp.MustCompile(`^ban ` + netBanAddress + ` expired$`)},
+		// [net_ban]: unbanned index 0 ('192.168.178.25' banned for 5 minutes (spam))
+		{lineUnban, []string{"net_ban"}, regexp.MustCompile(`^unbanned index [\d]+ \(` + netBanAddress + ` `)},
+		{lineUnban, []string{"net_ban"}, regexp.MustCompile(`^unbanned ` + netBanAddress)},
+		{lineUnbanAll, []string{"net_ban"}, regexp.MustCompile(`^unbanned all entries$`)},
+		{lineBanError, []string{"net_ban"}, regexp.MustCompile(`(?P<message>.*error.*)$`)},
+	}
+
+	// zCatch logs joins and leaves with their own log categories
+	zcatchProfile = &LogProfile{
+		Name:        "zcatch",
+		LineFormats: []*regexp.Regexp{timestampLineFormat, plainLineFormat},
+		Rules: joinRules(
+			[]lineRule{
+				{lineJoin, []string{"client_enter"}, regexp.MustCompile(`id=(?P<id>[\d]+) addr=(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+) version=(?P<version>\d+) name='(?P<name>.{0,20})' clan='(?P<clan>.{0,16})' country=(?P<country>[-\d]+)$`)},
+				{lineLeave, []string{"client_drop"}, regexp.MustCompile(`id=(?P<id>[\d]+) addr=[a-fA-F0-9\.\:\[\]]+ reason='(?P<reason>.*)'$`)},
+				status07Rule,
+				status06Rule,
+				syncMarkerRule,
+			},
+			voteRules,
+			voteTimeRules,
+			rconRules,
+			chatRules,
+			netBanRules,
+			[]lineRule{
+				{lineServer, []string{"Server"}, regexp.MustCompile(`.+`)},
+			},
+		),
+	}
+
+	// vanilla 0.6 logs the client IDs of joining players in hexadecimal,
+	// their names are known as soon as they join a team.
+	vanilla06Profile = &LogProfile{
+		Name:        "vanilla06",
+		LineFormats: []*regexp.Regexp{hexTimestampLineFormat, plainLineFormat},
+		Rules: joinRules(
+			[]lineRule{
+				{lineJoin, []string{"server"}, regexp.MustCompile(`^player has entered the game\. ClientID=(?P<xid>[0-9a-fA-F]+) addr=(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+)`)},
+				{lineName, []string{"game"}, regexp.MustCompile(`^team_join player='(?P<id>[\d]+):(?P<name>.*)' team=[-\d]+$`)},
+				{lineLeave, []string{"server"}, regexp.MustCompile(`^client dropped\. cid=(?P<id>[\d]+) addr=[^ ]+ reason='(?P<reason>.*)'$`)},
+				status06Rule,
+				syncMarkerRule,
+			},
+			voteRules,
+			voteTimeRules,
+			rconRules,
+			chatRules,
+			netBanRules,
+		),
+	}
+
+	vanilla07Profile = &LogProfile{
+		Name:        "vanilla07",
+		LineFormats: []*regexp.Regexp{timestampLineFormat, plainLineFormat},
+		Rules: joinRules(
+			[]lineRule{
+				{lineJoin, []string{"server"}, regexp.MustCompile(`^player has entered the game\. ClientID=(?P<id>[\d]+) addr=(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+)`)},
+				{lineName, []string{"game"}, regexp.MustCompile(`^team_join player='(?P<id>[\d]+):(?P<name>.*)' team=[-\d]+$`)},
+				{lineLeave, []string{"server"}, regexp.MustCompile(`^client dropped\. cid=(?P<id>[\d]+) addr=[^ ]+ reason='(?P<reason>.*)'$`)},
+				status07Rule,
+				syncMarkerRule,
+			},
+			voteRules,
+			voteTimeRules,
+			rconRules,
+			chatRules,
+			netBanRules,
+		),
+	}
+
+	// DDNet wraps addresses in <{...}> in order to be able to hide them in the logs.
+	ddnetProfile = &LogProfile{
+		Name:        "ddnet",
+		LineFormats: []*regexp.Regexp{ddnetLineFormat, timestampLineFormat, plainLineFormat},
+		Rules: joinRules(
+			[]lineRule{
+				{lineJoin, []string{"server"}, regexp.MustCompile(`^player has entered the game\. ClientID=(?P<id>[\d]+) addr=<\{(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+)\}>`)},
+				{lineName, []string{"game"}, regexp.MustCompile(`^team_join player='(?P<id>[\d]+):(?P<name>.*)' team=[-\d]+$`)},
+				{lineLeave, []string{"server"}, regexp.MustCompile(`^client dropped\. cid=(?P<id>[\d]+) addr=<\{[^}]+\}> reason='(?P<reason>.*)'$`)},
+				{lineStatus, []string{"Server", "server"}, regexp.MustCompile(`^id=(?P<id>[\d]+) addr=<\{(?P<ip>[a-fA-F0-9\.\:\[\]]+):(?P<port>[\d]+)\}> name='(?P<name>.*)' client=(?P<version>[\d]+)`)},
+				syncMarkerRule,
+			},
+			voteRules,
+			voteTimeRules,
+			rconRules,
+			chatRules,
+			netBanRules,
+		),
+	}
+)
+
+// joinRules concatenates the rules of a profile, the order is kept.
+func joinRules(rules ...[]lineRule) []lineRule {
+	result := make([]lineRule, 0, 32)
+	for _, r := range rules {
+		result = append(result, r...)
+	}
+	return result
+}
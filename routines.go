@@ -2,139 +2,147 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/jxsl13/twapi/econ"
 )
 
-var (
-	// [2020-05-22 23:01:09][client_enter]: id=0 addr=192.168.178.25:64139 version=1796 name='MisterFister:(' clan='FistingTea`' country=-1
-	// 0: full 1: timestamp 2: log level 3: log line
-	initialLoglevelRegex = regexp.MustCompile(`\[([\d -:]+)\]\[([^:]+)\]: (.+)$`)
-
-	// no timestamp
-	// [client_enter]: id=0 addr=192.168.178.25:64139 version=1796 name='MisterFister:(' clan='FistingTea`' country=-1
-	// 0: full 1: log level 2: log line
-	initial06LoglevelRegex = regexp.MustCompile(`\[([^:]+)\]: (.+)$`)
-
-	// logLevel: server
-	startVotekickRegex = regexp.MustCompile(`'([\d]{1,2}):(.*)' voted kick '([\d]{1,2}):(.*)' reason='(.{1,20})' cmd='(.*)' force=([\d])`)
-	startSpecVoteRegex = regexp.MustCompile(`'([\d]{1,2}):(.*)' voted spectate '([\d]{1,2}):(.*)' reason='(.{1,20})' cmd='(.*)' force=([\d])`)
-	startOptionVote    = regexp.MustCompile(`'([\d]{1,2}):(.*)' voted option '(.+)' reason='(.{1,20})' cmd='(.+)' force=([\d])`)
-
-	// 0: full 1: ID 2: rank
-	// logLevel: server
-	loginRconRegex     = regexp.MustCompile(`ClientID=(\d+) authed \((.*)\)`)
-	executeRconCommand = regexp.MustCompile(`ClientID=(\d+) rcon='(.*)'$`)
-
-	// logLevel: chat
-	chatRegex = regexp.MustCompile(`([-\d]+):[-\d]+:(.{1,16}): (.*)$`)
-	// logLevel: teamchat
-	teamChatRegex = regexp.MustCompile(`([-\d]+):[-\d]+:(.{1,16}): (.*)$`)
-	// logLevel: whisper
-	whisperRegex = regexp.MustCompile(`([-\d]+):[-\d]+:(.{1,16}): (.*)$`)
-
-	// logLevel: net_ban
-	bansErrorRegex = regexp.MustCompile(`(.*error.*)$`)
-
-	moderatorMentions = regexp.MustCompile(`\[chat\]: [\d]+:'.*': .*(@moderators|@mods|@mod|@administrators|@admins|@admin).*$`) // first plurals, then singular
-
-	formatedSpecVoteKickStringRegex = regexp.MustCompile(`\*\*\[.*vote.*\]\*\*\: ([\d]+):'(.{0,20})' [^\d]{12,15} ([\d]+):'(.{0,20})'( to spectators)? with reason '(.+)'$`)
+const (
+	// the server announces the outcome of a vote shortly after its vote time
+	voteOutcomeDelay = 5 * time.Second
+)
 
-	formatedBanRegex = regexp.MustCompile(`\*\*\[bans\]\*\*: '(.*)' banned for (.*) with reason: '(.*)'$`)
+var (
+	// ErrChannelNotFound is returned when a server is not bound to any Discord channel.
+	ErrChannelNotFound = errors.New("server is not bound to any channel")
 
-	// logLevel: server
-	forcedYesRegex = regexp.MustCompile(`forcing vote yes$`)
-	forcedNoRegex  = regexp.MustCompile(`forcing vote no$`)
+	// mentions in the text of chat messages, first plurals, then singular
+	moderatorMentions = regexp.MustCompile(`@moderators|@mods|@mod|@administrators|@admins|@admin`)
 )
 
-func serverRoutine(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, addr Address, pass password) {
+// startServerRoutine starts the moderation of an address that has already been bound to a channel.
+func (b *Bot) startServerRoutine(s DiscordSession, addr Address, pass password) {
+	ctx, cancel := context.WithCancel(b.ctx)
+	b.ServerRoutines.Set(addr, ctx, cancel)
+	go b.serverRoutine(ctx, s, addr, pass)
+}
+
+// serverRoutine moderates the server with the passed address in the channel the address is bound to.
+func (b *Bot) serverRoutine(ctx context.Context, s DiscordSession, addr Address, pass password) {
 	// sub goroutines
 	routineContext, routineCancel := context.WithCancel(ctx)
 	defer routineCancel()
 
 	// channel - server association
-	defer config.ChannelAddress.RemoveAddress(addr)
-	defer s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Stopped listening to server %s", addr))
-	initialMessageID := m.ID
-
-	config.AnnouncemenServers[addr] = NewAnnouncementServer(routineContext, config.DiscordCommandQueue[addr])
-
-	// econ connection
-	conn, err := econ.DialToWithOnConnectCommands(string(addr), string(pass), []string{"ec_output_level 2"})
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
-		return
-	}
-	defer conn.Close()
+	defer func() {
+		if ctx.Err() != nil {
+			// stopped by #unmoderate or by shutting down the bot
+			return
+		}
 
-	// cleanup all messages before the initial message
-	go cleanupRoutine(routineContext, s, m.ChannelID, initialMessageID)
+		b.sendToServerChannel(s, addr, fmt.Sprintf("Stopped listening to server %s", addr))
+		b.ServerRoutines.Cancel(addr)
+		b.ChannelAddress.RemoveAddress(addr)
+		b.SaveBindings()
+	}()
 
-	// start channel history cleanup
-	go logCleanupRoutine(routineContext, s, m.ChannelID, initialMessageID, addr)
+	b.AnnouncemenServers[addr] = NewAnnouncementServer(routineContext, b.DiscordCommandQueue[addr])
 
-	// execution of discord commands
-	go commandQueueRoutine(routineContext, s, m.ChannelID, conn, addr)
+	// execution of discord commands, which fail with ErrNotConnected until the bot is connected
+	var conn *EconConn
+	if _, ok := b.econAddress(addr); ok {
+		conn = &EconConn{}
+	}
+	go b.commandQueueRoutine(routineContext, s, conn, addr)
 
-	// handle econ line parsing
+	// read the log lines from the external console or the log file
 	result := make(chan string)
-	defer close(result)
 
-	// start routine for waiting for line
-	go func(ctx context.Context, conn *econ.Conn) {
+	if !b.openSource(routineContext, s, addr, pass, conn, result) {
+		return
+	}
 
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("Closing econ reader routine of:", addr, " : ", err.Error())
-				return
-			default:
-				line, err := conn.ReadLine()
-				if err != nil {
-					s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("econ read error: %s", err.Error()))
-					continue
-				}
-				result <- line
-			}
-		}
+	// start channel history cleanup
+	go b.logCleanupRoutine(routineContext, s, addr)
 
-	}(ctx, conn)
+	// synchronize the server state that the bot missed while not being connected
+	if conn != nil {
+		b.onEconConnect(conn, addr)
+		go b.synchronizationRoutine(routineContext, conn, addr)
+	}
 
+	server := b.ServerStates[addr]
+	parser := b.Parsers[addr]
 	for {
+		var event Event
 
 		// wait for read or abort
 		select {
 		case <-ctx.Done():
-			log.Printf("closing econ line parsing routine of: %s\n", addr)
+			log.Printf("closing line parsing routine of: %s\n", addr)
 			return
-		case line := <-result:
-			// if read avalable, parse and if necessary, send
-			fmtLine, send := parseEconLine(line, config.ServerStates[addr])
+		case line, ok := <-result:
+			if !ok {
+				// the log file cannot be read anymore
+				return
+			}
+			event = parser.Parse(line, server)
+		case e := <-server.Expired():
+			event = e
+		}
 
-			if send {
-				// check for moderator mention
-				fmtLine = replaceModeratorMentions(s, m, fmtLine)
+		if event == nil {
+			continue
+		}
+		server.handleEvent(event)
 
-				msg, err := s.ChannelMessageSend(m.ChannelID, fmtLine)
-				if err != nil {
-					log.Printf("error while sending line: %s\n", err.Error())
-				}
+		// if necessary, send
+		if !b.shown(event) {
+			continue
+		}
+		binding, ok := b.ChannelAddress.GetBinding(addr)
+		if !ok {
+			continue
+		}
 
-				handleMessageReactions(routineContext, s, msg, line, fmtLine)
-			}
+		fmtLine := ""
+		if chat, ok := event.(ChatEvent); ok {
+			fmtLine = b.renderModeratorMentions(s, binding, chat)
+		} else {
+			fmtLine = event.Render()
+		}
+		if fmtLine == "" {
+			continue
+		}
 
+		msg, err := s.ChannelMessageSendComplex(string(binding.ChannelID), &discordgo.MessageSend{
+			Content:    fmtLine,
+			Components: messageComponents(event),
+		})
+		if err != nil {
+			log.Printf("error while sending line: %s\n", err.Error())
+			continue
 		}
+
+		b.handleMessageButtons(routineContext, s, msg, addr, event)
+	}
+}
+
+// sendToServerChannel sends a message to the channel that the server is currently bound to.
+func (b *Bot) sendToServerChannel(s DiscordSession, addr Address, content string) (*discordgo.Message, error) {
+	channelID, ok := b.GetChannelIDByAddress(addr)
+	if !ok {
+		return nil, ErrChannelNotFound
 	}
+	return s.ChannelMessageSend(channelID, content)
 }
 
-func cleanupRoutine(routineContext context.Context, s *discordgo.Session, channelID, initialMessageID string) {
+func cleanupRoutine(routineContext context.Context, s DiscordSession, channelID, initialMessageID string) {
 	defer log.Println("finished cleaning up old messages.")
 
 	cleanedUpMessages := 0
@@ -158,9 +166,7 @@ loop:
 
 			const bulkDelay = 14*24*time.Hour - time.Minute
 			for _, msg := range messages {
-				timestamp, _ := msg.Timestamp.Parse()
-
-				if time.Since(timestamp) >= bulkDelay {
+				if time.Since(msg.Timestamp) >= bulkDelay {
 					bulkIDs = append(bulkIDs, msg.ID)
 				} else {
 					manualIDs = append(manualIDs, msg.ID)
@@ -190,7 +196,7 @@ loop:
 	log.Printf("deleted %d old messages.", cleanedUpMessages)
 }
 
-func logCleanupRoutine(routineContext context.Context, s *discordgo.Session, channelID, initialMessageID string, addr Address) {
+func (b *Bot) logCleanupRoutine(routineContext context.Context, s DiscordSession, addr Address) {
 
 	for {
 		timer := time.NewTimer(2 * time.Minute)
@@ -200,7 +206,13 @@ func logCleanupRoutine(routineContext context.Context, s *discordgo.Session, cha
 			log.Printf("closing main routine of: %s\n", addr)
 			return
 		case <-timer.C:
-			messages, err := s.ChannelMessages(channelID, 100, "", initialMessageID, "")
+			binding, ok := b.ChannelAddress.GetBinding(addr)
+			if !ok {
+				continue
+			}
+			channelID := string(binding.ChannelID)
+
+			messages, err := s.ChannelMessages(channelID, 100, "", binding.MessageID, "")
 			if err != nil {
 				log.Printf("error on cleanup: %s", err.Error())
 				continue
@@ -209,14 +221,8 @@ func logCleanupRoutine(routineContext context.Context, s *discordgo.Session, cha
 			cleanedUp := 0
 			for _, message := range messages {
 
-				created, err := message.Timestamp.Parse()
-				if err != nil {
-					log.Printf("error parsing message: %s", err.Error())
-					continue
-				}
-
 				// TODO: make variable
-				if time.Since(created) > 24*time.Hour {
+				if time.Since(message.Timestamp) > 24*time.Hour {
 
 					err := s.ChannelMessageDelete(channelID, message.ID)
 					if err != nil {
@@ -230,529 +236,402 @@ func logCleanupRoutine(routineContext context.Context, s *discordgo.Session, cha
 	}
 }
 
-func commandQueueRoutine(routineContext context.Context, s *discordgo.Session, channelID string, conn *econ.Conn, addr Address) {
+// econReaderRoutine reads lines from the external console at econAddr and reconnects with an
+// exponential backoff whenever the connection dies. The lines are discarded if result is nil.
+func (b *Bot) econReaderRoutine(routineContext context.Context, s DiscordSession, conn *EconConn, addr, econAddr Address, pass password, result chan<- string) {
+	defer log.Println("Closing econ reader routine of:", addr)
 
 	for {
-		select {
-		case <-routineContext.Done():
-			log.Printf("closing command queue routine of: %s\n", addr)
-			return
-		case cmd, ok := <-config.DiscordCommandQueue[addr]:
-			if !ok {
+		line, err := conn.ReadLine()
+		if err != nil {
+			if routineContext.Err() != nil {
 				return
 			}
 
-			lineToExecute, send, err := parseCommandLine(cmd.Command)
+			disconnectedAt := time.Now()
+			conn.Close()
+			log.Printf("lost econ connection to %s: %s\n", econAddr, err.Error())
+			b.sendToServerChannel(s, addr, fmt.Sprintf("**[econ]**: disconnected from %s, reconnecting...", econAddr))
+
+			link, err := dialEcon(routineContext, econAddr, pass)
 			if err != nil {
-				s.ChannelMessageSend(channelID, err.Error())
-				continue
+				return
 			}
-			if send {
-				escapedNick := strings.ReplaceAll(cmd.Author, "#", "_")
-				logLine := fmt.Sprintf("echo [Discord] user '%s' executed rcon '%s'", escapedNick, lineToExecute)
-				conn.WriteLine(logLine)
-				conn.WriteLine(lineToExecute)
+			conn.Reset(link)
+			if routineContext.Err() != nil {
+				conn.Close()
+				return
 			}
+			b.onEconConnect(conn, addr)
+
+			b.sendToServerChannel(s, addr, fmt.Sprintf("**[econ]**: reconnected to %s after %s", econAddr, time.Since(disconnectedAt).Round(time.Second)))
+			continue
 		}
-	}
-}
 
-func parseCommandLine(cmd string) (line string, send bool, err error) {
-	args := strings.Split(cmd, " ")
-	if len(args) < 1 {
-		return
-	}
+		if result == nil {
+			continue
+		}
 
-	return cmd, true, nil
+		select {
+		case <-routineContext.Done():
+			return
+		case result <- line:
+		}
+	}
 }
 
-func parseEconLine(line string, server *Server) (result string, send bool) {
+// onEconConnect requests the server state that cannot be derived from the log lines
+// that are received after connecting.
+func (b *Bot) onEconConnect(conn *EconConn, addr Address) {
+	err := requestStatus(conn, b.ServerStates[addr])
+	if err != nil {
+		log.Printf("failed to request the status of %s: %s\n", addr, err.Error())
+	}
 
-	var matches []string
-	logLevel := ""
-	logLine := ""
+	// the ban list is rebuilt from the response
+	err = conn.WriteLine("bans")
+	if err != nil {
+		log.Printf("failed to request the bans of %s: %s\n", addr, err.Error())
+	}
 
-	if matches = initialLoglevelRegex.FindStringSubmatch(line); len(matches) > 0 {
-		logLevel = matches[2]
-		logLine = matches[3]
-	} else if matches = initial06LoglevelRegex.FindStringSubmatch(line); len(matches) > 0 {
-		logLevel = matches[1]
-		logLine = matches[2]
-	} else {
-		return "", false
+	err = requestVoteTime(conn, b.ServerStates[addr])
+	if err != nil {
+		log.Printf("failed to request the vote time of %s: %s\n", addr, err.Error())
 	}
+}
 
-	switch logLevel {
-	case "client_enter", "client_drop":
-		if consumed, fmtLine := server.ParseLine(logLevel, logLine, config.JoinNotify); consumed {
-			result = fmtLine
-			if fmtLine != "" {
-				send = true
-			}
-		}
-		return
-	case "server":
-		if consumed, fmtLine := server.ParseLine(logLevel, logLine, config.JoinNotify); consumed {
-			result = fmtLine
-			if fmtLine != "" {
-				send = true
-			}
-			return
-		}
+// requestStatus requests the player list in order to synchronize the player slots.
+// The echoed marker tells the server when the status response is complete.
+func requestStatus(conn *EconConn, server *Server) error {
+	server.BeginSync()
 
-		var matches []string
-		matches = startOptionVote.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 7) {
-			votingID, _ := strconv.Atoi(matches[1])
-			votingName := matches[2]
+	err := conn.WriteLine("status")
+	if err != nil {
+		return err
+	}
+	return conn.WriteLine("echo " + statusSyncMarker)
+}
 
-			optionName := matches[3]
-			reason := matches[4]
+// requestVoteTime requests the sv_vote_time of the server, which tells how long its votes last.
+// The echoed marker ends the request, servers without sv_vote_time respond with an error instead.
+func requestVoteTime(conn *EconConn, server *Server) error {
+	server.Votes.BeginTimeSync()
 
-			forced := matches[6]
+	err := conn.WriteLine("sv_vote_time")
+	if err != nil {
+		return err
+	}
+	return conn.WriteLine("echo " + voteTimeSyncMarker)
+}
 
-			if forced == "1" {
-				forced = "/forced"
-			} else {
-				forced = ""
-			}
+// synchronizationRoutine periodically synchronizes the player slots and the ban list with the server.
+func (b *Bot) synchronizationRoutine(routineContext context.Context, conn *EconConn, addr Address) {
+	ticker := time.NewTicker(statusSyncInterval)
+	defer ticker.Stop()
 
-			result = fmt.Sprintf("**[optionvote%s]**: %d:'%s' voted option '%s' with reason '%s'", forced, votingID, Escape(votingName), Escape(optionName), Escape(reason))
-			send = true
+	for {
+		select {
+		case <-routineContext.Done():
+			log.Printf("closing synchronization routine of: %s\n", addr)
 			return
-		}
-
-		matches = startVotekickRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 7) {
-			kickingID, _ := strconv.Atoi(matches[1])
-			kickingName := matches[2]
-
-			kickedID, _ := strconv.Atoi(matches[3])
-			kickedName := matches[4]
-
-			reason := matches[5]
-			forced := matches[7]
-
-			if forced == "1" {
-				forced = "/forced"
-			} else {
-				forced = ""
+		case <-ticker.C:
+			err := requestStatus(conn, b.ServerStates[addr])
+			if err != nil {
+				log.Printf("failed to request the status of %s: %s\n", addr, err.Error())
 			}
 
-			result = fmt.Sprintf("**[kickvote%s]**: %d:'%s' started to kick %d:'%s' with reason '%s'", forced, kickingID, Escape(kickingName), kickedID, Escape(kickedName), Escape(reason))
-			send = true
-			return
-		}
-
-		matches = startSpecVoteRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 7) {
-			votingID, _ := strconv.Atoi(matches[1])
-			votingName := matches[2]
-
-			votedID, _ := strconv.Atoi(matches[3])
-			votedName := matches[4]
-
-			reason := matches[5]
-			forced := matches[7]
-
-			if forced == "1" {
-				forced = "/forced"
-			} else {
-				forced = ""
+			// the global bans are reconciled with the received ban list
+			err = conn.WriteLine("bans")
+			if err != nil {
+				log.Printf("failed to request the bans of %s: %s\n", addr, err.Error())
 			}
 
-			result = fmt.Sprintf("**[specvote%s]**: %d:'%s' wants to move %d:'%s' to spectators with reason '%s'", forced, votingID, Escape(votingName), votedID, Escape(votedName), Escape(reason))
-			send = true
-			return
-		}
-
-		matches = forcedYesRegex.FindStringSubmatch(logLine)
-		if len(matches) == 1 {
-			result = "**[server]**: Forced Yes"
-			send = true
-			return
-		}
-
-		matches = forcedNoRegex.FindStringSubmatch(logLine)
-		if len(matches) == 1 {
-			result = "**[server]**: Forced No"
-			send = true
-			return
-		}
-
-		matches = loginRconRegex.FindStringSubmatch(logLine)
-		if len(matches) == 3 {
-			id, _ := strconv.Atoi(matches[1])
-			rank := matches[2]
-
-			result = fmt.Sprintf("**[rcon]**: '%s' authed as **%s**", Escape(server.Player(id).Name), rank)
-			send = true
-			return
+			// the vote time might have been changed by a reloaded config
+			err = requestVoteTime(conn, b.ServerStates[addr])
+			if err != nil {
+				log.Printf("failed to request the vote time of %s: %s\n", addr, err.Error())
+			}
 		}
+	}
+}
 
-		matches = executeRconCommand.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 2) {
-			adminID, _ := strconv.Atoi(matches[1])
-			name := server.Player(adminID).Name
-			command := matches[2]
+func (b *Bot) commandQueueRoutine(routineContext context.Context, s DiscordSession, conn *EconConn, addr Address) {
 
-			result = fmt.Sprintf("**[rcon]**: '%s' command='%s'", Escape(name), Escape(command))
-			send = true
+	for {
+		select {
+		case <-routineContext.Done():
+			log.Printf("closing command queue routine of: %s\n", addr)
 			return
-		}
+		case cmd, ok := <-b.DiscordCommandQueue[addr]:
+			if !ok {
+				return
+			}
 
-		return
-	case "net_ban":
-		if consumed, fmtLine := server.ParseLine(logLevel, logLine, config.JoinNotify); consumed {
-			result = fmtLine
-			if fmtLine != "" {
-				send = true
+			// moderated via the log file without an econ endpoint
+			if conn == nil {
+				b.sendToServerChannel(s, addr, fmt.Sprintf("**[error]**: could not execute '%s': %s", Escape(cmd.Command), ErrCommandsDisabled.Error()))
+				continue
 			}
-			return
-		}
 
-		matches := bansErrorRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 1) {
-			errorMsg := matches[1]
-			result = fmt.Sprintf("**[error]**: %s", errorMsg)
-			send = true
-		}
+			lineToExecute, send, err := parseCommandLine(cmd.Command)
+			if err != nil {
+				b.sendToServerChannel(s, addr, err.Error())
+				continue
+			}
+			if send {
+				if isBanCommand(lineToExecute) || isVoteCommand(lineToExecute) {
+					b.ServerStates[addr].SetCommandAuthor(cmd.Author)
+				}
 
-		return
-	case "chat":
-		matches := chatRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 3) {
-			id, _ := strconv.Atoi(matches[1])
-			name := matches[2]
-			text := matches[3]
-
-			result = fmt.Sprintf("[chat]: %d:'%s': %s", id, Escape(name), Escape(text))
-			send = true
-		}
-		return
-	case "teamchat":
-		matches = teamChatRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 3) {
-			id, _ := strconv.Atoi(matches[1])
-			name := matches[2]
-			text := matches[3]
-
-			result = fmt.Sprintf("[teamchat]: %d:'%s': %s", id, Escape(name), Escape(text))
-			send = true
-		}
-		return
-	case "whisper":
-		matches = whisperRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 3) {
-			id, _ := strconv.Atoi(matches[1])
-			name := matches[2]
-			message := matches[3]
-
-			if config.LogLevel >= 1 || config.SpiedOnPlayers.Contains(name) {
-				result = fmt.Sprintf("[whisper] %d:'%s': %s", id, Escape(name), Escape(message))
-				send = true
+				escapedNick := strings.ReplaceAll(cmd.Author, "#", "_")
+				logLine := fmt.Sprintf("echo [Discord] user '%s' executed rcon '%s'", escapedNick, lineToExecute)
+				conn.WriteLine(logLine)
+				err = conn.WriteLine(lineToExecute)
+				if err != nil {
+					b.sendToServerChannel(s, addr, fmt.Sprintf("**[error]**: could not execute '%s': %s", Escape(lineToExecute), err.Error()))
+				} else {
+					// commands of the external console are not logged by the server
+					b.ServerStates[addr].Votes.TimeCommand(lineToExecute)
+				}
 			}
 		}
-		return
-	case "Server":
-		result = fmt.Sprintf("[server]: %s", Escape(logLine))
-		send = true
-		return
 	}
-	return
 }
 
-func replaceModeratorMentions(s *discordgo.Session, m *discordgo.MessageCreate, line string) string {
-
-	// rate limit mentions
-	if !config.AllowMention(m.ChannelID) {
+// isBanCommand returns true if the command might ban or unban a player, e.g. ban, unban or ban_range.
+func isBanCommand(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && strings.Contains(fields[0], "ban")
+}
 
-		// if mentions in cooldown, make mention bold formated
-		matches := moderatorMentions.FindStringSubmatch(line)
-		if len(matches) == (1 + 1) {
-			mention := matches[1]
-			return strings.ReplaceAll(line, mention, fmt.Sprintf("**%s**", mention))
-		}
+// isVoteCommand returns true if the command forces the current vote.
+func isVoteCommand(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && fields[0] == "vote"
+}
 
-		// don't replace anything
-		return line
+func parseCommandLine(cmd string) (line string, send bool, err error) {
+	args := strings.Split(cmd, " ")
+	if len(args) < 1 {
+		return
 	}
 
-	matches := moderatorMentions.FindStringSubmatch(line)
-	if len(matches) == (1 + 1) {
-
-		// there is a role configured
-		if len(config.DiscordModeratorRole) > 0 {
-			mention := matches[1]
+	return cmd, true, nil
+}
 
-			roles, _ := s.GuildRoles(m.GuildID)
+// renderModeratorMentions formats the chat message and replaces the moderator mentions of its text with the
+// moderator role. Rate limited mentions are only highlighted.
+func (b *Bot) renderModeratorMentions(s DiscordSession, binding ChannelBinding, e ChatEvent) string {
+	if e.Type != ChatAll {
+		return e.Render()
+	}
 
-			mentionReplace := ""
-			for _, role := range roles {
-				if strings.Contains(role.Name, config.DiscordModeratorRole) {
-					mentionReplace = role.Mention()
-					break
-				}
-			}
+	mention := moderatorMentions.FindString(e.Text)
+	if mention == "" {
+		return e.Render()
+	}
 
-			if len(mentionReplace) > 0 {
-				return strings.ReplaceAll(line, mention, mentionReplace)
+	replacement := ""
+	if !b.AllowMention(string(binding.ChannelID)) {
+		replacement = fmt.Sprintf("**%s**", mention)
+	} else if len(b.DiscordModeratorRole) > 0 {
+		roles, _ := s.GuildRoles(binding.GuildID)
+		for _, role := range roles {
+			if strings.Contains(role.Name, b.DiscordModeratorRole) {
+				replacement = role.Mention()
+				break
 			}
-			return line
 		}
 	}
-	return line
-}
-
-func handleMessageReactions(routineContext context.Context, s *discordgo.Session, msg *discordgo.Message, line, fmtLine string) {
-
-	if strings.Contains(fmtLine, "[kickvote") || strings.Contains(fmtLine, "[specvote") {
+	if replacement == "" {
+		return e.Render()
+	}
 
-		// add reactions to force vote via reactions instead of commands
-		errF3 := s.MessageReactionAdd(msg.ChannelID, msg.ID, config.F3Emoji())
-		errF4 := s.MessageReactionAdd(msg.ChannelID, msg.ID, config.F4Emoji())
-		errBan := s.MessageReactionAdd(msg.ChannelID, msg.ID, config.BanEmoji())
+	// only the text is changed, the rest of the message is escaped as usual
+	parts := strings.Split(e.Text, mention)
+	for idx, part := range parts {
+		parts[idx] = Escape(part)
+	}
+	return e.render(strings.Join(parts, replacement))
+}
 
-		if errF3 != nil || errF4 != nil || errBan != nil {
-			fmtStr := "You have configured an incorrect F3_EMOJI, F4_EMOJI or BAN_EMOJI: \n\t%s\n\t%s\n\t%s\n"
-			log.Printf(fmtStr, config.F3Emoji(), config.F4Emoji(), config.BanEmoji())
-			config.ResetEmojis()
+// handleMessageButtons waits for the moderators to click the buttons of votes and bans
+// and keeps the messages of votes up to date until the votes ended.
+func (b *Bot) handleMessageButtons(routineContext context.Context, s DiscordSession, msg *discordgo.Message, addr Address, event Event) {
 
-			s.MessageReactionAdd(msg.ChannelID, msg.ID, config.F3Emoji())
-			s.MessageReactionAdd(msg.ChannelID, msg.ID, config.F4Emoji())
-			s.MessageReactionAdd(msg.ChannelID, msg.ID, config.BanEmoji())
-		}
+	switch e := event.(type) {
+	case VoteStartEvent:
+		server := b.ServerStates[addr]
 
-		matches := formatedSpecVoteKickStringRegex.FindStringSubmatch(fmtLine)
-		votingPlayer := Player{ID: -1}
-		votedPlayer := Player{ID: -1}
+		// the vote ends as soon as the server reports its outcome, shortly after the server's vote time at the latest
+		timeoutContext, cancelTimeout := context.WithTimeout(routineContext, server.Votes.Time()+voteOutcomeDelay)
+		voteContext, cancel := server.Votes.WatchContext(timeoutContext, e.ID)
 
-		if len(matches) == 7 {
-			votingID, _ := strconv.Atoi(matches[1])
-			votedID, _ := strconv.Atoi(matches[3])
-			server, ok := config.GetServerByChannelID(msg.ChannelID)
-			if !ok {
-				return
-			}
-
-			votingPlayer = server.Player(votingID)
-			votedPlayer = server.Player(votedID)
-		}
+		// the message is registered before anyone can click its buttons
+		clicks := b.PendingButtons.Watch(voteContext, msg.ID)
 
 		// handle votes.
-		go voteReactionsRoutine(routineContext, s, msg, votingPlayer, votedPlayer)
-
-	} else if strings.Contains(fmtLine, "**[bans]**") {
-		matches := formatedBanRegex.FindStringSubmatch(fmtLine)
-		if len(matches) != 4 {
+		go func() {
+			defer cancelTimeout()
+			defer cancel()
+			b.voteRoutine(routineContext, voteContext, s, msg, clicks, addr, e)
+		}()
+
+	case BanEvent:
+		if e.Type != BanEventBan {
 			return
 		}
 
-		nickname := matches[1]
-		reason := matches[3]
-
-		addr, ok := config.GetAddressByChannelID(msg.ChannelID)
+		server := b.ServerStates[addr]
+		playerBan, ok := server.BanServer.GetBanByIP(e.Player.IP)
 		if !ok {
+			disableButtons(s, msg, unbanComponents(true))
 			return
 		}
 
-		server := config.ServerStates[addr]
-		playerBan, ok := server.BanServer.GetBanByNameAndReason(nickname, reason)
-		if !ok {
+		// cancelled by the ban server as soon as the ban is removed
+		watchContext, cancel := server.BanServer.WatchContext(routineContext, playerBan.Player.IP)
+		if _, ok := server.BanServer.GetBanByIP(playerBan.Player.IP); !ok {
+			cancel()
+			disableButtons(s, msg, unbanComponents(true))
 			return
 		}
+		clicks := b.PendingButtons.Watch(watchContext, msg.ID)
 
-		go func(routineContext context.Context, s *discordgo.Session, msg *discordgo.Message, playerBan Ban) {
-
+		go func(watchContext context.Context, s DiscordSession, msg *discordgo.Message, playerBan Ban) {
+			defer cancel()
 			defer log.Println("Stopping ban tracking routine of:", playerBan.Player.Name)
 
-			err := s.MessageReactionAdd(msg.ChannelID, msg.ID, config.UnbanEmoji())
-			if err != nil {
-				fmtStr := "You have configured an incorrect UNBAN_EMOJI:\n\t%s\n"
-				log.Printf(fmtStr, config.UnbanEmoji())
-				config.ResetEmojis()
-
-				s.MessageReactionAdd(msg.ChannelID, msg.ID, config.UnbanEmoji())
-			}
-
 			for {
 				select {
-				case <-routineContext.Done():
-					return
-				default:
-					if playerBan.Expired() {
-						return
-					}
-
-					time.Sleep(1 * time.Second)
-
-					unbanUsers, err := s.MessageReactions(msg.ChannelID, msg.ID, config.UnbanEmoji(), 10)
-					if err != nil {
-						return
+				case <-watchContext.Done():
+					if routineContext.Err() == nil {
+						// the ban expired or was removed, there is nothing to unban anymore
+						disableButtons(s, msg, unbanComponents(true))
 					}
-
-					// bot's reaction
-					if len(unbanUsers) == 1 {
+					return
+				case click := <-clicks:
+					if click.CustomID != unbanButtonID {
 						continue
 					}
 
-					for _, unbanUser := range unbanUsers {
-						discordUser := unbanUser.String()
-
-						if config.DiscordModerators.Contains(discordUser) {
-
-							addr, _ := config.ChannelAddress.Get(discordChannel(msg.ChannelID))
-
-							config.DiscordCommandQueue[addr] <- command{
-								Author:  discordUser,
-								Command: fmt.Sprintf("unban %s", playerBan.Player.IP),
-							}
-							return
-						}
-
-					}
+					b.queueCommand(addr, command{
+						Author:  click.Author,
+						Command: unbanCommand(playerBan.Player.IP),
+					})
+					return
 				}
 			}
 
-		}(routineContext, s, msg, playerBan)
+		}(watchContext, s, msg, playerBan)
 	}
 }
 
-func voteReactionsRoutine(routineContext context.Context, s *discordgo.Session, msg *discordgo.Message, votingPlayer, votedPlayer Player) {
+// voteRoutine forces the vote or bans the voting player as soon as a moderator clicks a button of the vote.
+// Once the vote ended, the message of the vote is edited to show the outcome and its buttons are removed.
+func (b *Bot) voteRoutine(routineContext, voteContext context.Context, s DiscordSession, msg *discordgo.Message, clicks <-chan buttonClick, addr Address, e VoteStartEvent) {
+	server := b.ServerStates[addr]
 
-	// a vote takes 30 seconds
-	end := time.Now().Add(30 * time.Second)
 	for {
 		select {
-		case <-routineContext.Done():
-			return
-		default:
-
-			// stopping routine, as the vote timed out.
-			if time.Now().After(end) {
+		case <-voteContext.Done():
+			if routineContext.Err() != nil {
 				return
 			}
 
-			time.Sleep(time.Second)
-
-			f3Users, errF3 := s.MessageReactions(msg.ChannelID, msg.ID, config.F3Emoji(), 10)
-			f4Users, errF4 := s.MessageReactions(msg.ChannelID, msg.ID, config.F4Emoji(), 10)
-			banUsers, errBan := s.MessageReactions(msg.ChannelID, msg.ID, config.BanEmoji(), 10)
-			if errF3 != nil || errF4 != nil || errBan != nil {
-				log.Println("Resetting vote emojis to default values, as they could not be retrieved.")
-				config.ResetEmojis()
+			// the server did not report the outcome in time
+			server.Votes.Expire(e.ID)
 
-				f3Users, _ = s.MessageReactions(msg.ChannelID, msg.ID, config.F3Emoji(), 10)
-				f4Users, _ = s.MessageReactions(msg.ChannelID, msg.ID, config.F4Emoji(), 10)
-				banUsers, _ = s.MessageReactions(msg.ChannelID, msg.ID, config.BanEmoji(), 10)
-			}
-
-			if len(f3Users) == 1 && len(f4Users) == 1 && len(banUsers) == 1 {
-				// the bot's reaction, no user interaction, yet
-				continue
+			vote, ok := server.Votes.Get(e.ID)
+			if !ok {
+				return
 			}
-
-			// check for f3 votes
-			for _, f3User := range f3Users {
-
-				discordUser := f3User.String()
-
-				if config.DiscordModerators.Contains(discordUser) {
-
-					addr, _ := config.ChannelAddress.Get(discordChannel(msg.ChannelID))
-
-					config.DiscordCommandQueue[addr] <- command{
-						Author:  discordUser,
-						Command: "vote yes",
-					}
-					return
+			showVoteOutcome(s, msg, vote)
+			return
+		case click := <-clicks:
+			switch click.CustomID {
+			case voteYesButtonID:
+				b.queueCommand(addr, command{
+					Author:  click.Author,
+					Command: "vote yes",
+				})
+			case voteNoButtonID:
+				b.queueCommand(addr, command{
+					Author:  click.Author,
+					Command: "vote no",
+				})
+			case voteBanButtonID:
+				if e.Type == VoteOption {
+					// option votes have no ban button
+					continue
 				}
+				go b.banVotingPlayer(routineContext, addr, click.Author, e.Voter)
 			}
 
-			// check f4 votes
-			for _, f4User := range f4Users {
-
-				discordUser := f4User.String()
-
-				if config.DiscordModerators.Contains(discordUser) {
-
-					if config.DiscordModerators.Contains(discordUser) {
+			// the buttons are disabled after the first click, wait for the outcome
+			clicks = nil
+		}
+	}
+}
 
-						addr, _ := config.ChannelAddress.Get(discordChannel(msg.ChannelID))
+// showVoteOutcome adds the outcome to the message of the vote and removes its buttons.
+func showVoteOutcome(s DiscordSession, msg *discordgo.Message, vote Vote) {
+	content := msg.Content + "\n" + vote.Render()
+	components := []discordgo.MessageComponent{}
+
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		log.Printf("error while showing the outcome of a vote: %s", err.Error())
+	}
+}
 
-						config.DiscordCommandQueue[addr] <- command{
-							Author:  discordUser,
-							Command: "vote no",
-						}
-						return
-					}
-				}
-			}
+// banVotingPlayer bans the player that started a vote and aborts the vote.
+func (b *Bot) banVotingPlayer(routineContext context.Context, addr Address, discordUser string, votingPlayer Player) {
+	server := b.ServerStates[addr]
+
+	player := server.PlayerByIP(votingPlayer.IP)
+	if player.Valid() {
+		// use online player's ID to ban him
+		b.queueCommand(addr, command{
+			Author:  discordUser,
+			Command: withReason(b.withEscalatedDuration(fmt.Sprintf(b.BanReplacementIDCommand, player.ID), votingPlayer), defaultBanReason),
+		})
+
+		// abort vote in any case
+		b.queueCommand(addr, command{
+			Author:  discordUser,
+			Command: "vote no",
+		})
+		return
+	}
 
-			// check ban votes
-			for _, banUser := range banUsers {
-				if config.DiscordModerators.Contains(banUser.String()) {
-
-					discordUser := banUser.String()
-
-					if config.DiscordModerators.Contains(discordUser) {
-
-						server, _ := config.GetServerByChannelID(msg.ChannelID)
-						addr, _ := config.GetAddressByChannelID(msg.ChannelID)
-
-						player := server.PlayerByIP(votingPlayer.IP)
-						if player.Valid() {
-							// use online player's ID to ban him
-							config.DiscordCommandQueue[addr] <- command{
-								Author:  discordUser,
-								Command: fmt.Sprintf(config.BanReplacementIDCommand, player.ID),
-							}
-
-							// abort vote in any case
-							config.DiscordCommandQueue[addr] <- command{
-								Author:  discordUser,
-								Command: "vote no",
-							}
-						} else {
-							// use the IP instead, when the player is not online.
-							config.DiscordCommandQueue[addr] <- command{
-								Author:  discordUser,
-								Command: fmt.Sprintf(config.BanReplacementIPCommand, votingPlayer.IP),
-							}
-
-							// abort vote in any case
-							config.DiscordCommandQueue[addr] <- command{
-								Author:  discordUser,
-								Command: "vote no",
-							}
-
-							retries := 10
-							for {
-								select {
-								case <-routineContext.Done():
-								default:
-									retries--
-									if retries < 0 {
-										return
-									}
-
-									ok := server.BanServer.SetPlayerAfterwards(votingPlayer)
-									if !ok {
-										time.Sleep(time.Second)
-										continue
-									}
-
-									return
-								}
-							}
-						}
-
-						return
-					}
-				}
-			}
+	// use the IP instead, when the player is not online.
+	b.queueCommand(addr, command{
+		Author:  discordUser,
+		Command: withReason(b.withEscalatedDuration(fmt.Sprintf(b.BanReplacementIPCommand, votingPlayer.IP), votingPlayer), defaultBanReason),
+	})
+
+	// abort vote in any case
+	b.queueCommand(addr, command{
+		Author:  discordUser,
+		Command: "vote no",
+	})
+
+	// the player of the ban is set as soon as the server reports the ban
+	for retries := 10; retries > 0; retries-- {
+		if server.BanServer.SetPlayerAfterwards(votingPlayer) {
+			return
+		}
 
-			// continue checking for emote changes
+		select {
+		case <-time.After(time.Second):
+		case <-routineContext.Done():
+			return
 		}
 	}
 }
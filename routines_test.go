@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jxsl13/TeeworldsEconDiscordModerationBot/discordtest"
+	"github.com/jxsl13/TeeworldsEconDiscordModerationBot/econtest"
+)
+
+const testTimeout = 10 * time.Second
+
+// moderateFakeServer creates a bot that moderates a fake external console in the passed channel.
+func moderateFakeServer(t *testing.T, econ *econtest.Server, session *discordtest.Session, channelID string) (*Bot, context.CancelFunc) {
+	t.Helper()
+
+	addr := Address(econ.Addr())
+	b, err := New(Options{
+		DiscordToken: "token",
+		DiscordAdmin: "admin#0001",
+		Servers: []ServerOptions{{
+			Address:  addr,
+			Password: "pw",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.AddInteractionHandler(func(i *discordgo.InteractionCreate) {
+		b.InteractionCreateHandler(session, i)
+	})
+	b.ChannelAddress.Set(ChannelBinding{
+		ChannelID: discordChannel(channelID),
+		GuildID:   "guild",
+		Address:   addr,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.serverRoutine(ctx, session, addr, "pw")
+
+	// the status request is answered as soon as the bot is connected
+	if _, err := econ.WaitForCommand("echo "+statusSyncMarker, testTimeout); err != nil {
+		cancel()
+		t.Fatalf("bot did not request the status: %v", err)
+	}
+	return b, cancel
+}
+
+// waitForButtons blocks until the bot waits for the clicks on the buttons of the message.
+func waitForButtons(t *testing.T, b *Bot, msg *discordgo.Message) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for b.PendingButtons.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("bot does not wait for the buttons of message %s", msg.ID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// buttonsDisabled returns true if the message has buttons and all of them are disabled.
+func buttonsDisabled(components []discordgo.MessageComponent) bool {
+	found := false
+	for _, component := range components {
+		row, ok := component.(discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, c := range row.Components {
+			button, ok := c.(discordgo.Button)
+			if !ok {
+				continue
+			}
+			if !button.Disabled {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// waitForCommandCount blocks until the command was executed at least n times.
+func waitForCommandCount(t *testing.T, econ *econtest.Server, cmd string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		count := 0
+		for _, executed := range econ.Commands() {
+			if executed == cmd {
+				count++
+			}
+		}
+		if count >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q to be executed %d times, executed: %q", cmd, n, econ.Commands())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerRoutine_Reconnect(t *testing.T) {
+	econ, err := econtest.NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer econ.Close()
+
+	session := discordtest.NewSession()
+
+	const channelID = "reconnect"
+	_, cancel := moderateFakeServer(t, econ, session, channelID)
+	defer cancel()
+
+	if _, err := econ.WaitForCommand("echo "+voteTimeSyncMarker, testTimeout); err != nil {
+		t.Fatalf("bot did not finish connecting: %v, executed: %q", err, econ.Commands())
+	}
+
+	// e.g. a server restart
+	econ.Disconnect()
+
+	if _, err := session.WaitForMessage(channelID, "**[econ]**: disconnected from", testTimeout); err != nil {
+		t.Fatalf("disconnect was not announced: %v, messages: %#v", err, session.Messages(channelID))
+	}
+	if _, err := session.WaitForMessage(channelID, "**[econ]**: reconnected to", testTimeout); err != nil {
+		t.Fatalf("reconnect was not announced: %v, messages: %#v", err, session.Messages(channelID))
+	}
+
+	// the server state is synchronized again
+	for _, cmd := range []string{"status", "bans", "sv_vote_time"} {
+		waitForCommandCount(t, econ, cmd, 2)
+	}
+}
+
+func TestServerRoutine_StartupOutage(t *testing.T) {
+	econ, err := econtest.NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server is offline when the bot starts
+	addr := Address(econ.Addr())
+	econ.Close()
+
+	b, err := New(Options{
+		DiscordToken: "token",
+		DiscordAdmin: "admin#0001",
+		Servers:      []ServerOptions{{Address: addr, Password: "pw"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const channelID = "outage"
+	session := discordtest.NewSession()
+	b.ChannelAddress.Set(ChannelBinding{
+		ChannelID: channelID,
+		GuildID:   "guild",
+		Address:   addr,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.serverRoutine(ctx, session, addr, "pw")
+
+	if _, err := session.WaitForMessage(channelID, "**[econ]**: could not connect to", testTimeout); err != nil {
+		t.Fatalf("connection error was not announced: %v, messages: %#v", err, session.Messages(channelID))
+	}
+
+	if !b.queueCommand(addr, command{Author: "admin#0001", Command: "status"}) {
+		t.Fatal("command queue is not processed while the bot is connecting")
+	}
+	if _, err := session.WaitForMessage(channelID, ErrNotConnected.Error(), testTimeout); err != nil {
+		t.Fatalf("command did not fail: %v, messages: %#v", err, session.Messages(channelID))
+	}
+}
+
+func TestServerRoutine_KickVoteBanButton(t *testing.T) {
+	econ, err := econtest.NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer econ.Close()
+
+	session := discordtest.NewSession()
+	moderator := &discordgo.User{ID: "1", Username: "moderator", Discriminator: "0001"}
+
+	const channelID = "kickvote"
+	b, cancel := moderateFakeServer(t, econ, session, channelID)
+	defer cancel()
+	b.DiscordModerators.Add(moderator.String())
+
+	econ.Emit(
+		"[client_enter]: id=3 addr=192.168.178.25:64139 version=1796 name='voter' clan='' country=-1",
+		"[client_enter]: id=5 addr=192.168.178.26:64140 version=1796 name='voted' clan='' country=-1",
+		"[2020-05-22 23:01:09][server]: '3:voter' voted kick '5:voted' reason='spam' cmd='ban 5 5 spam' force=0",
+	)
+
+	msg, err := session.WaitForMessage(channelID, "[kickvote]", testTimeout)
+	if err != nil {
+		t.Fatalf("kickvote was not sent: %v", err)
+	}
+
+	if len(msg.Components) == 0 || buttonsDisabled(msg.Components) {
+		t.Fatalf("kickvote has no buttons: %#v", msg.Components)
+	}
+	waitForButtons(t, b, msg)
+
+	resp, err := session.Click(channelID, msg.ID, voteBanButtonID, moderator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buttonsDisabled(resp.Data.Components) {
+		t.Errorf("buttons must be disabled after the vote was handled")
+	}
+
+	ban, err := econ.WaitForCommand("ban 3 ", testTimeout)
+	if err != nil {
+		t.Fatalf("voter was not banned: %v, executed: %q", err, econ.Commands())
+	}
+	if strings.Contains(ban, minutesPlaceholder) {
+		t.Errorf("ban duration was not replaced: %s", ban)
+	}
+
+	if _, err := econ.WaitForCommand("vote no", testTimeout); err != nil {
+		t.Fatalf("vote was not aborted: %v, executed: %q", err, econ.Commands())
+	}
+
+	econ.Emit(
+		"[2020-05-22 23:01:10][server]: forcing vote no",
+		"[2020-05-22 23:01:10][chat]: -1:-1:*** Vote failed",
+	)
+
+	outcome := "**[vote failed]**: forced no by " + Escape(moderator.String())
+	msg, err = session.WaitForMessage(channelID, outcome, testTimeout)
+	if err != nil {
+		t.Fatalf("vote message was not edited: %v, messages: %#v", err, session.Messages(channelID))
+	}
+	if !strings.HasPrefix(msg.Content, "**[kickvote]**") || len(msg.Components) != 0 {
+		t.Errorf("the outcome must be added to the vote and the buttons must be removed: %#v", msg)
+	}
+}
+
+func TestServerRoutine_OptionVote(t *testing.T) {
+	econ, err := econtest.NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer econ.Close()
+
+	session := discordtest.NewSession()
+	moderator := &discordgo.User{ID: "1", Username: "moderator", Discriminator: "0001"}
+
+	// the vote time is requested as soon as the bot is connected
+	econ.Handle("sv_vote_time", "[Console]: Value: 20")
+
+	const channelID = "optionvote"
+	b, cancel := moderateFakeServer(t, econ, session, channelID)
+	defer cancel()
+	b.DiscordModerators.Add(moderator.String())
+
+	if _, err := econ.WaitForCommand("echo "+voteTimeSyncMarker, testTimeout); err != nil {
+		t.Fatalf("bot did not request the vote time: %v, executed: %q", err, econ.Commands())
+	}
+
+	econ.Emit(
+		"[client_enter]: id=3 addr=192.168.178.25:64139 version=1796 name='voter' clan='' country=-1",
+		"[2020-05-22 23:01:09][server]: '3:voter' voted option 'Map: ctf5' reason='No reason given' cmd='sv_map ctf5' force=0",
+	)
+
+	msg, err := session.WaitForMessage(channelID, "[optionvote]", testTimeout)
+	if err != nil {
+		t.Fatalf("option vote was not sent: %v", err)
+	}
+	if voteTime := b.ServerStates[Address(econ.Addr())].Votes.Time(); voteTime != 20*time.Second {
+		t.Errorf("expected the vote time of the server, got %s", voteTime)
+	}
+
+	if len(msg.Components) == 0 || buttonsDisabled(msg.Components) || hasButton(msg.Components, voteBanButtonID) {
+		t.Fatalf("option votes can only be forced: %#v", msg.Components)
+	}
+	waitForButtons(t, b, msg)
+
+	resp, err := session.Click(channelID, msg.ID, voteYesButtonID, moderator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buttonsDisabled(resp.Data.Components) || hasButton(resp.Data.Components, voteBanButtonID) {
+		t.Errorf("buttons must be disabled after the vote was handled: %#v", resp.Data.Components)
+	}
+
+	if _, err := econ.WaitForCommand("vote yes", testTimeout); err != nil {
+		t.Fatalf("vote was not forced: %v, executed: %q", err, econ.Commands())
+	}
+}
+
+func TestServerRoutine_UnbanButton(t *testing.T) {
+	econ, err := econtest.NewServer("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer econ.Close()
+
+	session := discordtest.NewSession()
+	moderator := &discordgo.User{ID: "1", Username: "moderator", Discriminator: "0001"}
+	user := &discordgo.User{ID: "2", Username: "user", Discriminator: "0002"}
+
+	const channelID = "unban"
+	b, cancel := moderateFakeServer(t, econ, session, channelID)
+	defer cancel()
+	b.DiscordModerators.Add(moderator.String())
+
+	econ.Emit("[net_ban]: banned '192.168.178.26' for 5 minutes (spam)")
+
+	msg, err := session.WaitForMessage(channelID, "[bans]", testTimeout)
+	if err != nil {
+		t.Fatalf("ban was not sent: %v", err)
+	}
+	waitForButtons(t, b, msg)
+
+	// only moderators can unban
+	resp, err := session.Click(channelID, msg.ID, unbanButtonID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("non-moderators must be answered privately: %#v", resp.Data)
+	}
+
+	if _, err := session.Click(channelID, msg.ID, unbanButtonID, moderator); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := econ.WaitForCommand("unban 192.168.178.26", testTimeout); err != nil {
+		t.Fatalf("player was not unbanned: %v, executed: %q", err, econ.Commands())
+	}
+	unbans := 0
+	for _, cmd := range econ.Commands() {
+		if strings.HasPrefix(cmd, "unban ") {
+			unbans++
+		}
+	}
+	if unbans != 1 {
+		t.Errorf("expected a single unban, executed: %q", econ.Commands())
+	}
+}
+
+func TestRenderModeratorMentions(t *testing.T) {
+	b := newTestBot()
+	b.DiscordModeratorRole = "Moderator"
+	b.MentionLimiter[testAddress] = NewRateLimiter(time.Hour)
+
+	session := discordtest.NewSession()
+	session.SetRoles("guild", &discordgo.Role{ID: "42", Name: "Server Moderator"})
+	binding := ChannelBinding{ChannelID: testChannelID, GuildID: "guild", Address: testAddress}
+
+	// crafted nicknames must not mention anyone
+	line := b.renderModeratorMentions(session, binding, ChatEvent{Type: ChatAll, ID: 1, Name: "@mods", Text: "hi"})
+	if line != "[chat]: 1:'@mods': hi" {
+		t.Errorf("unexpected line: %s", line)
+	}
+
+	line = b.renderModeratorMentions(session, binding, ChatEvent{Type: ChatAll, ID: 1, Name: "@mods", Text: "@mods help_me"})
+	if line != "[chat]: 1:'@mods': <@&42> help\\_me" {
+		t.Errorf("unexpected line: %s", line)
+	}
+
+	// rate limited
+	line = b.renderModeratorMentions(session, binding, ChatEvent{Type: ChatAll, ID: 1, Name: "griefer", Text: "@admin"})
+	if line != "[chat]: 1:'griefer': **@admin**" {
+		t.Errorf("unexpected line: %s", line)
+	}
+}
@@ -2,9 +2,7 @@ package main
 
 import (
 	"errors"
-	"fmt"
-	"regexp"
-	"strconv"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -18,21 +16,18 @@ import (
 var (
 	// ErrPlayerNotFound is returned by the ip matching player search function if no player was found.
 	ErrPlayerNotFound = errors.New("player not found")
+)
 
-	// 0: full 1: ID 2: IP 3: port 4: version 5: name 6: clan 7: country
-	playerEnteredRegex = regexp.MustCompile(`id=([\d]+) addr=([a-fA-F0-9\.\:\[\]]+):([\d]+) version=(\d+) name='(.{0,20})' clan='(.{0,16})' country=([-\d]+)$`)
+const (
+	maxPlayers = 64
 
-	// 0: full 1: ID 2: IP 3: reason
-	playerLeftRegex = regexp.MustCompile(`id=([\d]+) addr=([a-fA-F0-9\.\:\[\]]+) reason='(.*)'$`)
+	// echoed after the status command in order to know when the status response is complete
+	statusSyncMarker = "[Discord] synchronized player slots"
 
-	// logLevel: net_ban
-	banAddRegex   = regexp.MustCompile(`^banned '(.*)' for ([\d]+) minute[s]? \((.*)\)$`)
-	banAddIPRegex = regexp.MustCompile(`^'(.*)' banned for ([\d]+) minute[s]? \((.*)\)$`)
+	statusSyncInterval = 5 * time.Minute
 
-	banRemoveIndexRegex = regexp.MustCompile(`^unbanned index [\d]+ \('(.+)'\)`)
-	banRemoveIPRegex    = regexp.MustCompile(`^unbanned '(.+)'`)
-	banExpiredRegex     = regexp.MustCompile(`^ban '(.+)' expired$`)
-	banRemoveAll        = regexp.MustCompile(`^unbanned all entries$`)
+	// ban events within this time after a Discord command are associated with the command's author
+	commandAuthorTimeout = 5 * time.Second
 )
 
 // Player represents an ingame player.
@@ -60,23 +55,48 @@ func (p *Player) Clear() {
 
 // Server represents a tracked Teeworlds server
 type Server struct {
-	sync.RWMutex   // guards slots object
-	players        [64]Player
-	BanServer      BanServer
-	JoinCallbacks  []PlayerCallback
-	LeaveCallbacks []PlayerCallback
+	sync.RWMutex     // guards slots object
+	players          [maxPlayers]Player
+	syncing          bool
+	synced           [maxPlayers]bool
+	pendingBans      []Ban
+	commandAuthor    string
+	commandAuthorAt  time.Time
+	BanServer        BanServer
+	Votes            VoteTracker
+	expired          chan BanEvent
+	JoinCallbacks    []PlayerCallback
+	LeaveCallbacks   []PlayerCallback
+	BanCallbacks     []BanCallback
+	BanListCallbacks []BanListCallback
+	EventCallbacks   []EventCallback
+
+	// set by the bot, both may be nil
+	JoinNotify      *NotifyMap // users that are mentioned when a player joins
+	NicknameTracker *NicknameTracker
 }
 
 // PlayerCallback is a function that takes a player as parameter.
 type PlayerCallback func(Player)
 
+// BanCallback is a function that takes a ban event as parameter.
+type BanCallback func(BanEvent)
+
+// BanListCallback is a function that takes the complete ban list of a server as parameter.
+type BanListCallback func([]Ban)
+
 // NewServer creates a new empty server
 func NewServer() *Server {
 	srv := &Server{
-		BanServer:      newBanServer(),
-		JoinCallbacks:  make([]PlayerCallback, 0, 1),
-		LeaveCallbacks: make([]PlayerCallback, 0, 1),
+		BanServer:        newBanServer(),
+		JoinCallbacks:    make([]PlayerCallback, 0, 1),
+		LeaveCallbacks:   make([]PlayerCallback, 0, 1),
+		BanCallbacks:     make([]BanCallback, 0, 1),
+		BanListCallbacks: make([]BanListCallback, 0, 1),
+		EventCallbacks:   make([]EventCallback, 0, 1),
+		expired:          make(chan BanEvent, 16),
 	}
+	srv.BanServer.SetExpiryHandler(srv.handleExpiry)
 
 	for idx := range srv.players {
 		srv.Lock()
@@ -87,159 +107,211 @@ func NewServer() *Server {
 	return srv
 }
 
-// ParseLine parses a line from econ or logs, which affects the internal server state.
-func (s *Server) ParseLine(logLevel, logLine string, notify *NotifyMap) (consumed bool, formatedString string) {
-
-	switch logLevel {
-	case "client_enter":
-		match := playerEnteredRegex.FindStringSubmatch(logLine)
-		if len(match) == 8 {
+// PlayerEntered adds a player that entered the server.
+// Players whose name is not known yet are announced as soon as PlayerNamed is called.
+func (s *Server) PlayerEntered(player Player) Event {
+	if player.ID < 0 || maxPlayers <= player.ID {
+		return nil
+	}
 
-			id, _ := strconv.Atoi(match[1])
-			port, _ := strconv.Atoi(match[3])
-			version, _ := strconv.Atoi(match[4])
-			country, _ := strconv.Atoi(match[7])
+	s.Lock()
+	s.players[player.ID] = player
+	s.synced[player.ID] = true
+	s.Unlock()
 
-			player := Player{
-				ID:      id,
-				Name:    match[5],
-				Clan:    match[6],
-				Country: country,
-				IP:      match[2],
-				Port:    port,
-				Version: version,
-			}
+	if player.Name == "" {
+		return nil
+	}
+	return s.joined(player)
+}
 
-			s.Lock()
-			s.players[id] = player
-			s.Unlock()
+// PlayerNamed sets the name of a player that entered the server without a name.
+func (s *Server) PlayerNamed(id int, name string) Event {
+	if id < 0 || maxPlayers <= id {
+		return nil
+	}
 
-			s.handleJoin(player)
+	s.Lock()
+	player := s.players[id]
+	if !player.Valid() || player.Name != "" {
+		// name is already known, e.g. the player changed the team
+		s.Unlock()
+		return nil
+	}
+	player.Name = name
+	s.players[id] = player
+	s.Unlock()
 
-			// notification requested
-			if notify != nil {
-				var sb strings.Builder
+	return s.joined(player)
+}
 
-				mentions := notify.Tracked(player.Name)
-				if len(mentions) > 0 {
+func (s *Server) joined(player Player) Event {
+	s.handleJoin(player)
 
-					for idx, mention := range mentions {
-						sb.WriteString(mention)
-						if idx < len(mentions)-1 {
-							sb.WriteString(" ")
-						}
-					}
+	e := JoinEvent{Player: player}
 
-					return true, fmt.Sprintf("[server]: '%s' joined the server with id %d\n%s", Escape(player.Name), id, sb.String())
-				}
+	// notification requested
+	if s.JoinNotify != nil {
+		e.Mentions = s.JoinNotify.Tracked(player.Name)
+	}
+	return e
+}
 
-			}
+// PlayerLeft clears the slot of a player that left the server.
+func (s *Server) PlayerLeft(id int, reason string) Event {
+	if id < 0 || maxPlayers <= id {
+		return nil
+	}
 
-			if config.LogLevel >= 2 {
-				return true, fmt.Sprintf("[server]: '%s' joined the server with id %d", player.Name, id)
-			}
+	s.Lock()
+	// make copy
+	player := s.players[id]
 
-			return true, ""
-		}
-	case "client_drop":
-		// player leaves
-		match := playerLeftRegex.FindStringSubmatch(logLine)
-		if len(match) == 4 {
-			id, _ := strconv.Atoi(match[1])
-
-			s.Lock()
-			// make copy
-			player := s.players[id]
-
-			// clear player slot
-			s.players[id].Clear()
-			s.Unlock()
-
-			s.handleLeave(player)
-
-			if config.LogLevel >= 2 {
-				return true, fmt.Sprintf("[server]: '%s' left the server, id was %d", Escape(player.Name), id)
-			}
-			return true, ""
-		}
-	case "net_ban":
-		matches := banAddRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 3) {
-			ip := matches[1]
-			minutes, _ := strconv.Atoi(matches[2])
-			reason := matches[3]
+	// clear player slot
+	s.players[id].Clear()
+	s.Unlock()
 
-			// returns (unknown) dummy if player was not found
-			p := s.PlayerByIP(ip)
-			duration := time.Minute * time.Duration(minutes)
+	s.handleLeave(player)
+	return LeaveEvent{Player: player, Reason: reason}
+}
 
-			s.BanServer.Ban(p, duration, reason)
+// UpdateStatus updates a player slot with a line of the status command's response.
+// It returns true if the bot requested the status in order to synchronize the slots.
+func (s *Server) UpdateStatus(player Player) (syncing bool) {
+	if player.ID < 0 || maxPlayers <= player.ID {
+		return false
+	}
 
-			// player found, send nickname
-			return true, fmt.Sprintf("**[bans]**: '%s' banned for %9s with reason: '%s'", p.Name, duration.Round(time.Second), reason)
+	s.Lock()
+	previous := s.players[player.ID]
+	s.players[player.ID] = player
+	s.synced[player.ID] = true
+	syncing = s.syncing
+	s.Unlock()
+
+	if previous.IP != player.IP || previous.Port != player.Port {
+		if previous.Valid() {
+			s.handleLeave(previous)
 		}
+		s.handleJoin(player)
+	}
+	return syncing
+}
 
-		matches = banAddIPRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 3) {
-			ip := matches[1]
-			minutes, _ := strconv.Atoi(matches[2])
-			reason := matches[3]
-
-			p := s.PlayerByIP(ip)
-			duration := time.Minute * time.Duration(minutes)
+// AddBan adds a ban that was reported by the server, zero minutes ban permanently.
+func (s *Server) AddBan(ip string, minutes int, reason string) BanEvent {
+	// returns (unknown) dummy if player was not found
+	p := s.PlayerByIP(ip)
+	duration := time.Minute * time.Duration(minutes)
+
+	s.BanServer.Ban(p, duration, reason)
+	return s.handleBan(BanEvent{
+		Type:     BanEventBan,
+		Player:   p,
+		Duration: duration,
+		Reason:   reason,
+	})
+}
 
-			s.BanServer.Ban(p, duration, reason)
+// AddBanListEntry collects an entry of the ban list that is received after executing the bans command.
+func (s *Server) AddBanListEntry(ip string, minutes int, reason string) {
+	ban := Ban{
+		Player: s.bannedPlayer(ip),
+		Reason: reason,
+	}
+	if minutes > 0 {
+		ban.ExpiresAt = time.Now().Add(time.Minute * time.Duration(minutes))
+	}
 
-			// player found, send nickname
-			return true, fmt.Sprintf("**[bans]**: '%s' banned for %9s with reason: '%s'", p.Name, duration.Round(time.Second), reason)
-		}
+	s.Lock()
+	s.pendingBans = append(s.pendingBans, ban)
+	s.Unlock()
+}
 
-		matches = banExpiredRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 1) {
-			ip := matches[1]
+// EndBanList replaces the ban list with the entries that were collected since the last call.
+func (s *Server) EndBanList() {
+	s.Lock()
+	bans := s.pendingBans
+	s.pendingBans = nil
+	s.Unlock()
+
+	// the server's ban list is sorted the same way as ours,
+	// which is why the indices match.
+	s.BanServer.Replace(bans)
+	s.handleBanList(bans)
+}
 
-			ban, err := s.BanServer.UnbanIP(ip)
+// RemoveBan removes a ban that was removed or expired on the server.
+// Nil is returned if the ban already expired on time.
+func (s *Server) RemoveBan(eventType BanEventType, ip string) Event {
+	// already handled by the ban server's timer
+	if eventType == BanEventExpire && s.BanServer.ExpiredRecently(ip) {
+		return nil
+	}
 
-			if err != nil {
-				return true, fmt.Sprintf("[bans]: ban of '%s' expired", ban.Player.Name)
-			}
+	ban, err := s.BanServer.UnbanIP(ip)
+	return s.handleUnban(eventType, ip, ban, err)
+}
 
-			return true, fmt.Sprintf("[bans]: ban of '%s' expired (%s)", ban.Player.Name, ban.Reason)
-		}
+// RemoveAllBans removes all bans after the server's ban list was cleared.
+func (s *Server) RemoveAllBans() Event {
+	for _, ban := range s.BanServer.Bans() {
+		s.handleUnban(BanEventUnban, ban.Player.IP, ban, nil)
+	}
+	s.BanServer.UnbanAll()
+	return UnbanAllEvent{}
+}
 
-		matches = banRemoveIndexRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 1) {
-			ip := matches[1]
+// BeginSync marks all player slots as not synchronized.
+// Slots that are not updated until EndSync is called are cleared.
+func (s *Server) BeginSync() {
+	s.Lock()
+	defer s.Unlock()
 
-			ban, err := s.BanServer.UnbanIP(ip)
+	s.syncing = true
+	s.synced = [maxPlayers]bool{}
+}
 
-			if err != nil {
-				return true, fmt.Sprintf("[bans]: unbanned '%s'", ban.Player.Name)
-			}
+// EndSync clears all player slots that have not been updated since BeginSync was called.
+func (s *Server) EndSync() {
+	s.Lock()
+	if !s.syncing {
+		s.Unlock()
+		return
+	}
+	s.syncing = false
 
-			return true, fmt.Sprintf("[bans]: unbanned '%s' (%s)", ban.Player.Name, ban.Reason)
+	stale := make([]Player, 0, 1)
+	for idx := range s.players {
+		if !s.synced[idx] && s.players[idx].Valid() {
+			stale = append(stale, s.players[idx])
+			s.players[idx].Clear()
 		}
+	}
+	s.Unlock()
 
-		matches = banRemoveIPRegex.FindStringSubmatch(logLine)
-		if len(matches) == (1 + 1) {
-			ip := matches[1]
-
-			ban, err := s.BanServer.UnbanIP(ip)
+	for _, player := range stale {
+		s.handleLeave(player)
+	}
+}
 
-			if err != nil {
-				return true, fmt.Sprintf("[bans]: unbanned '%s'", ban.Player.Name)
-			}
-			return true, fmt.Sprintf("[bans]: unbanned '%s' (%s)", ban.Player.Name, ban.Reason)
-		}
+// bannedPlayer looks for the player that is banned with the passed IP
+// in the current ban list, the online players and the nickname tracking.
+func (s *Server) bannedPlayer(ip string) Player {
+	if ban, ok := s.BanServer.GetBanByIP(ip); ok {
+		return ban.Player
+	}
 
-		matches = banRemoveAll.FindStringSubmatch(logLine)
-		if len(matches) == 1 {
-			s.BanServer.UnbanAll()
-			return true, fmt.Sprintf("[bans]: unbanned all players.")
-		}
+	p := s.PlayerByIP(ip)
+	if p.Valid() {
+		return p
 	}
 
-	return false, ""
+	nicknames, err := s.NicknameTracker.Nicknames(ip)
+	if err == nil && len(nicknames) > 0 {
+		p.Name = strings.Join(nicknames, ", ")
+	}
+	return p
 }
 
 // Player returns the player by its ID.
@@ -258,7 +330,12 @@ func (s *Server) Player(id int) Player {
 }
 
 // PlayerByIP returns a dummy player with a negative ID if no player with expected IP was found.
+// IP ranges return a dummy player that is named after the online players within the range.
 func (s *Server) PlayerByIP(ip string) Player {
+	if r, err := ParseIPRange(ip); err == nil && !r.Single() {
+		return s.playerByIPRange(ip, r)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -275,6 +352,27 @@ func (s *Server) PlayerByIP(ip string) Player {
 	}
 }
 
+func (s *Server) playerByIPRange(ip string, r IPRange) Player {
+	names := make([]string, 0, 1)
+
+	for _, p := range s.Status() {
+		if r.Contains(net.ParseIP(p.IP)) {
+			names = append(names, p.Name)
+		}
+	}
+
+	name := "(unknown)"
+	if len(names) > 0 {
+		name = strings.Join(names, ", ")
+	}
+
+	return Player{
+		Name: name,
+		ID:   -1,
+		IP:   ip,
+	}
+}
+
 // Status returns a list of all online players
 func (s *Server) Status() []Player {
 	playerList := make([]Player, 0, 32)
@@ -314,3 +412,92 @@ func (s *Server) handleLeave(p Player) {
 		cb(p)
 	}
 }
+
+// AddBanHandler add a new ban event handler.
+func (s *Server) AddBanHandler(handler BanCallback) {
+	s.BanCallbacks = append(s.BanCallbacks, handler)
+}
+
+// AddBanListHandler adds a new handler that is called whenever the ban list of the server was received.
+func (s *Server) AddBanListHandler(handler BanListCallback) {
+	s.BanListCallbacks = append(s.BanListCallbacks, handler)
+}
+
+// AddEventHandler adds a new handler that is called with every event of the server that is sent to Discord.
+func (s *Server) AddEventHandler(handler EventCallback) {
+	s.EventCallbacks = append(s.EventCallbacks, handler)
+}
+
+// SetCommandAuthor remembers the Discord user that executed a ban or vote related command
+// in order to associate the resulting ban events and forced votes with that user.
+func (s *Server) SetCommandAuthor(author string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.commandAuthor = author
+	s.commandAuthorAt = time.Now()
+}
+
+// calls all callbacks, when a player is banned, unbanned or a ban expires.
+// The completed event is returned.
+func (s *Server) handleBan(e BanEvent) BanEvent {
+	e.Time = time.Now()
+
+	s.RLock()
+	if e.Type != BanEventExpire && time.Since(s.commandAuthorAt) <= commandAuthorTimeout {
+		e.Author = s.commandAuthor
+	}
+	s.RUnlock()
+
+	for _, cb := range s.BanCallbacks {
+		cb(e)
+	}
+	return e
+}
+
+// handleUnban creates the event of a removed ban, the ban is unknown if err is not nil.
+func (s *Server) handleUnban(eventType BanEventType, ip string, ban Ban, err error) BanEvent {
+	if err != nil {
+		ban.Player = Player{ID: -1, IP: ip}
+	}
+
+	return s.handleBan(BanEvent{
+		Type:   eventType,
+		Player: ban.Player,
+		Reason: ban.Reason,
+	})
+}
+
+// calls all callbacks, when an event was parsed.
+func (s *Server) handleEvent(e Event) {
+	for _, cb := range s.EventCallbacks {
+		cb(e)
+	}
+}
+
+// calls all callbacks, when the ban list was received.
+func (s *Server) handleBanList(bans []Ban) {
+	for _, cb := range s.BanListCallbacks {
+		cb(bans)
+	}
+}
+
+// handleExpiry is called by the ban server when a ban expired on time.
+func (s *Server) handleExpiry(ban Ban) {
+	e := s.handleBan(BanEvent{
+		Type:   BanEventExpire,
+		Player: ban.Player,
+		Reason: ban.Reason,
+	})
+
+	select {
+	case s.expired <- e:
+	default:
+		// nobody is listening
+	}
+}
+
+// Expired returns the events of bans that expired on time, they are not reported by the server's log lines afterwards.
+func (s *Server) Expired() <-chan BanEvent {
+	return s.expired
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServer_Sync(t *testing.T) {
+	s := NewServer()
+
+	zcatchProfile.ParseLine("client_enter", "id=3 addr=192.168.178.25:64139 version=1796 name='stale' clan='' country=-1", s)
+	zcatchProfile.ParseLine("client_enter", "id=5 addr=192.168.178.26:64140 version=1796 name='online' clan='' country=-1", s)
+
+	s.BeginSync()
+
+	consumed, event := zcatchProfile.ParseLine("Server", "id=5 addr=192.168.178.26:64140 client=0x0704 secure=yes name='online' clan='clan' country=276", s)
+	if !consumed || event != nil {
+		t.Fatalf("status line should be consumed silently, got: %#v", event)
+	}
+
+	consumed, _ = zcatchProfile.ParseLine("Server", "id=7 addr=192.168.178.27:64141 name='old client' score=3", s)
+	if !consumed {
+		t.Fatal("0.6 status line should be consumed")
+	}
+
+	consumed, _ = zcatchProfile.ParseLine("Console", statusSyncMarker, s)
+	if !consumed {
+		t.Fatal("status marker should be consumed")
+	}
+
+	stale := s.Player(3)
+	if stale.Valid() {
+		t.Fatal("stale player slot should have been cleared")
+	}
+
+	online := s.Player(5)
+	if online.Name != "online" || online.Clan != "clan" || online.Country != 276 || online.Version != 0x704 {
+		t.Fatalf("unexpected player: %+v", online)
+	}
+
+	if s.Player(7).Name != "old client" {
+		t.Fatalf("unexpected player: %+v", s.Player(7))
+	}
+
+	if len(s.Status()) != 2 {
+		t.Fatalf("Expected 2 players, got %d", len(s.Status()))
+	}
+}
+
+func TestServer_BanList(t *testing.T) {
+	s := NewServer()
+
+	zcatchProfile.ParseLine("client_enter", "id=5 addr=192.168.178.26:64140 version=1796 name='online' clan='' country=-1", s)
+	s.BanServer.Ban(Player{ID: -1, Name: "known", IP: "192.168.178.30"}, time.Hour, "old reason")
+
+	lines := []string{
+		"#0 '192.168.178.30' banned for 5 minutes (spam)",
+		"#1 '192.168.178.26' banned for 1 minute (flame)",
+		"#2 '192.168.178.31' banned for life (cheats)",
+	}
+
+	for _, line := range lines {
+		consumed, event := zcatchProfile.ParseLine("net_ban", line, s)
+		if !consumed || event != nil {
+			t.Fatalf("ban list entry should be consumed silently, got: %#v", event)
+		}
+	}
+
+	if s.BanServer.Size() != 1 {
+		t.Fatal("ban list should not be replaced before the list is complete")
+	}
+
+	zcatchProfile.ParseLine("net_ban", "3 bans", s)
+
+	bans := s.BanServer.Bans()
+	if len(bans) != 3 {
+		t.Fatalf("Expected 3 bans, got %d", len(bans))
+	}
+
+	expected := []struct {
+		name   string
+		reason string
+	}{
+		{"known", "spam"},
+		{"online", "flame"},
+		{"(unknown)", "cheats"},
+	}
+
+	for idx, e := range expected {
+		if bans[idx].Player.Name != e.name || bans[idx].Reason != e.reason {
+			t.Fatalf("idx=%d: Expected '%s' (%s), got '%s' (%s)", idx, e.name, e.reason, bans[idx].Player.Name, bans[idx].Reason)
+		}
+	}
+
+	if !bans[2].Permanent() || bans[2].Expired() {
+		t.Fatal("ban for life should be permanent")
+	}
+
+	_, event := zcatchProfile.ParseLine("net_ban", "banned '192.168.178.0' - '192.168.178.255' for 10 minutes (bots)", s)
+	if e, ok := event.(BanEvent); !ok || e.Type != BanEventBan || e.Duration != 10*time.Minute || e.Reason != "bots" {
+		t.Fatalf("unexpected ban event: %#v", event)
+	}
+
+	ban, ok := s.BanServer.GetBanByIP("192.168.178.0 - 192.168.178.255")
+	if !ok {
+		t.Fatal("range ban should be tracked")
+	}
+
+	if r, ok := ban.Range(); !ok || r.Size().Int64() != 256 {
+		t.Fatal("range ban should contain 256 IPs")
+	}
+
+	if ban.Player.Name != "online" {
+		t.Fatalf("range ban should be named after the online players, got '%s'", ban.Player.Name)
+	}
+
+	zcatchProfile.ParseLine("net_ban", "#0 '192.168.178.0' - '192.168.178.255' banned for 10 minutes (bots)", s)
+	zcatchProfile.ParseLine("net_ban", "1 ban", s)
+	if _, ok := s.BanServer.GetBanByIP("192.168.178.0 - 192.168.178.255"); !ok || s.BanServer.Size() != 1 {
+		t.Fatalf("range ban list entry should be tracked, got: %#v", s.BanServer.Bans())
+	}
+
+	_, event = zcatchProfile.ParseLine("net_ban", "unbanned '192.168.178.0' - '192.168.178.255'", s)
+	if e, ok := event.(BanEvent); !ok || e.Type != BanEventUnban || s.BanServer.Size() != 0 {
+		t.Fatalf("unexpected unban event: %#v", event)
+	}
+}
+
+func TestBanServer_WatchContext(t *testing.T) {
+	var bans BanServer
+	bans.Ban(Player{ID: -1, Name: "long", IP: "192.168.178.31"}, time.Hour, "flame")
+
+	for i := 0; i < 3; i++ {
+		_, cancel := bans.WatchContext(context.Background(), "192.168.178.31")
+		cancel()
+	}
+
+	// cancelled watchers are removed in the background
+	deadline := time.Now().Add(time.Second)
+	for bans.watcherCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected cancelled watchers to be removed, %d are left", bans.watcherCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_BanExpiry(t *testing.T) {
+	s := NewServer()
+
+	s.BanServer.Ban(Player{ID: -1, Name: "short", IP: "192.168.178.30"}, 50*time.Millisecond, "spam")
+	s.BanServer.Ban(Player{ID: -1, Name: "long", IP: "192.168.178.31"}, time.Hour, "flame")
+
+	watch, cancel := s.BanServer.WatchContext(context.Background(), "192.168.178.30")
+	defer cancel()
+
+	select {
+	case ban := <-s.Expired():
+		if ban.Player.Name != "short" {
+			t.Fatalf("unexpected expired ban: %+v", ban)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ban did not expire on time")
+	}
+
+	select {
+	case <-watch.Done():
+	default:
+		t.Fatal("watcher of the expired ban should have been cancelled")
+	}
+
+	if s.BanServer.Size() != 1 {
+		t.Fatalf("Expected 1 remaining ban, got %d", s.BanServer.Size())
+	}
+
+	consumed, event := zcatchProfile.ParseLine("net_ban", "ban '192.168.178.30' expired", s)
+	if !consumed || event != nil {
+		t.Fatalf("expiry message of an already expired ban should be ignored, got: %#v", event)
+	}
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// options with these names are autocompleted
+	playerOption   = "player"
+	nicknameOption = "nickname"
+	addressOption  = "address"
+
+	// option of the commands that are passed to the external console
+	argumentsOption = "arguments"
+
+	// Discord shows at most 25 suggestions
+	maxAutocompleteChoices = 25
+)
+
+// application command names must be lowercase
+var applicationCommandNameRegex = regexp.MustCompile(`^[-_\p{Ll}\p{N}]{1,32}$`)
+
+// slashCommand mirrors a ? or # command as a Discord application command.
+// The options are converted back into the arguments of the text command, in the order of Args
+// or in the order of Options by default. Boolean options add their name to the arguments.
+type slashCommand struct {
+	Description string
+	Admin       bool // only the admin can execute the command
+	Options     []*discordgo.ApplicationCommandOption
+	Args        []string
+}
+
+func stringOption(name, description string, required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         name,
+		Description:  description,
+		Required:     required,
+		Autocomplete: name == playerOption || name == nicknameOption || name == addressOption,
+	}
+}
+
+func integerOption(name, description string, required bool, minValue float64) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionInteger,
+		Name:        name,
+		Description: description,
+		Required:    required,
+		MinValue:    &minValue,
+	}
+}
+
+func booleanOption(name, description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        name,
+		Description: description,
+	}
+}
+
+// slashCommands are the built-in moderator and admin commands.
+var slashCommands = map[string]slashCommand{
+	"help": {
+		Description: "Shows the moderator commands and the moderators.",
+	},
+	"status": {
+		Description: "Shows the online players.",
+		Options: []*discordgo.ApplicationCommandOption{
+			booleanOption("ips", "Shows the IPs of the players, administrator only."),
+		},
+	},
+	"bans": {
+		Description: "Shows the bans of the server, optionally filtered and sorted.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("filter", "e.g. name=nameless tee reason=spam longer=1h sort=age", false),
+		},
+	},
+	"multiban": {
+		Description: "Bans a player or an IP range on all servers, without minutes the duration is escalated.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(playerOption, "ID of an online player or an IP range", true),
+			stringOption("reason", "reason of the ban", true),
+			integerOption("minutes", "duration of the ban", false, 1),
+		},
+		Args: []string{playerOption, "minutes", "reason"},
+	},
+	"multiunban": {
+		Description: "Removes a ban of the server's ban list on all servers.",
+		Options: []*discordgo.ApplicationCommandOption{
+			integerOption("id", "ID of the ban, see /bans", true, 0),
+		},
+	},
+	"notify": {
+		Description: "Mentions you when a player with the nickname joins.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname of the player", true),
+		},
+	},
+	"unnotify": {
+		Description: "Removes all of your join notifications.",
+	},
+	"whois": {
+		Description: "Shows the nicknames that were used with the same IPs.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname of the player", true),
+		},
+	},
+	"banhistory": {
+		Description: "Shows the bans, unbans and expired bans of a nickname or an IP.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname or IP of the player", true),
+		},
+	},
+	"punish": {
+		Description: "Bans a player with a duration that is escalated based on the previous bans.",
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(playerOption, "ID of an online player", true),
+			stringOption("reason", "reason of the ban", true),
+		},
+	},
+	"globalbans": {
+		Description: "Shows the bans that are enforced on all servers.",
+	},
+	"globalban": {
+		Description: "Enforces a ban of the server's ban list on all servers.",
+		Options: []*discordgo.ApplicationCommandOption{
+			integerOption("id", "ID of the ban, see /bans", true, 0),
+		},
+	},
+	"localban": {
+		Description: "Stops enforcing a ban of the server's ban list on the other servers.",
+		Options: []*discordgo.ApplicationCommandOption{
+			integerOption("id", "ID of the ban, see /bans", true, 0),
+		},
+	},
+	"ips": {
+		Description: "Shows the IPs that were used with a nickname.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname of the player", true),
+		},
+	},
+	"announce": {
+		Description: "Adds an announcement of the server.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("interval", "e.g. 5m or 1h30m", true),
+			stringOption("message", "announced message", true),
+		},
+	},
+	"unannounce": {
+		Description: "Removes an announcement of the server.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			integerOption("index", "index of the announcement, see /announcements", true, 0),
+		},
+	},
+	"announcements": {
+		Description: "Shows the announcements of the server.",
+		Admin:       true,
+	},
+	"add": {
+		Description: "Adds a moderator.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("user", "e.g. name#1234", true),
+		},
+	},
+	"remove": {
+		Description: "Removes a moderator.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("user", "e.g. name#1234", true),
+		},
+	},
+	"purge": {
+		Description: "Removes all moderators.",
+		Admin:       true,
+	},
+	"clean": {
+		Description: "Deletes the messages of the channel.",
+		Admin:       true,
+	},
+	"moderate": {
+		Description: "Moderates the server in this channel.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(addressOption, "address of the server", true),
+		},
+	},
+	"unmoderate": {
+		Description: "Stops the moderation of the server of this channel or of the passed server.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(addressOption, "address of the server", false),
+		},
+	},
+	"rebind": {
+		Description: "Moves the moderation of the server to this channel.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(addressOption, "address of the server", true),
+		},
+	},
+	"spy": {
+		Description: "Shows the whispers of a player.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname of the player", true),
+		},
+	},
+	"unspy": {
+		Description: "Stops showing the whispers of a player.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(nicknameOption, "nickname of the player", true),
+		},
+	},
+	"purgespy": {
+		Description: "Stops showing the whispers of all players.",
+		Admin:       true,
+	},
+	"execute": {
+		Description: "Executes a command in the external console.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("command", "e.g. sv_map ctf5", true),
+		},
+	},
+	"bulkmultiban": {
+		Description: "Bans IPs, CIDR networks and IP ranges on all servers.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption("ips", "e.g. 123.0.0.1 123.0.0.0/24 123.0.0.1-123.0.0.20", true),
+			stringOption("duration", "e.g. 1h5m", true),
+			stringOption("reason", "reason of the ban", true),
+		},
+	},
+	"confirmban": {
+		Description: "Confirms a pending range ban of a moderator.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			integerOption("id", "ID of the pending range ban", true, 0),
+		},
+	},
+	"exportbans": {
+		Description: "Uploads the ban list of the server or the global ban list.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "format",
+				Description: "format of the file, json by default",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: banFormatJSON, Value: banFormatJSON},
+					{Name: banFormatCSV, Value: banFormatCSV},
+					{Name: banFormatCfg, Value: banFormatCfg},
+				},
+			},
+			booleanOption("global", "Exports the global ban list."),
+		},
+	},
+	"importbans": {
+		Description: "Bans all entries of a ban file on the server or globally.",
+		Admin:       true,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Name:        "file",
+				Description: "json, csv or cfg ban file",
+				Required:    true,
+			},
+			booleanOption("global", "Bans the entries on all servers."),
+		},
+	},
+}
+
+// slashCommand returns the built-in command or the moderator command that is passed to the external console.
+func (b *Bot) slashCommand(name string) (slashCommand, bool) {
+	if cmd, ok := slashCommands[name]; ok {
+		return cmd, true
+	}
+
+	if !b.DiscordModeratorCommands.Contains(name) || !applicationCommandNameRegex.MatchString(name) {
+		return slashCommand{}, false
+	}
+
+	return slashCommand{
+		Description: fmt.Sprintf("Executes %s in the external console.", name),
+		Options: []*discordgo.ApplicationCommandOption{
+			stringOption(argumentsOption, "arguments of the command", false),
+		},
+	}, true
+}
+
+// applicationCommands returns the built-in commands and the configured moderator commands, sorted by name.
+func (b *Bot) applicationCommands() []*discordgo.ApplicationCommand {
+	names := make([]string, 0, len(slashCommands))
+	for name := range slashCommands {
+		names = append(names, name)
+	}
+	for _, name := range b.DiscordModeratorCommands.Commands() {
+		if _, builtin := slashCommands[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	commands := make([]*discordgo.ApplicationCommand, 0, len(names))
+	for _, name := range names {
+		cmd, ok := b.slashCommand(name)
+		if !ok {
+			log.Printf("moderator command %q cannot be registered as slash command", name)
+			continue
+		}
+
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        name,
+			Description: cmd.Description,
+			Options:     cmd.Options,
+		})
+	}
+	return commands
+}
+
+// RegisterApplicationCommands replaces the slash commands of the bot with the current commands.
+func (b *Bot) RegisterApplicationCommands(s DiscordSession, appID string) error {
+	_, err := s.ApplicationCommandBulkOverwrite(appID, "", b.applicationCommands())
+	return err
+}
+
+// slashCommandArgs converts the options of an executed slash command into the arguments of the text command.
+// Attachment options are returned separately.
+func slashCommandArgs(cmd slashCommand, data discordgo.ApplicationCommandInteractionData) (string, []*discordgo.MessageAttachment) {
+	order := cmd.Args
+	if order == nil {
+		order = make([]string, 0, len(cmd.Options))
+		for _, o := range cmd.Options {
+			order = append(order, o.Name)
+		}
+	}
+
+	args := make([]string, 0, len(order))
+	attachments := make([]*discordgo.MessageAttachment, 0, 1)
+
+	for _, name := range order {
+		o := data.GetOption(name)
+		if o == nil {
+			continue
+		}
+
+		switch o.Type {
+		case discordgo.ApplicationCommandOptionString:
+			args = append(args, strings.TrimSpace(o.StringValue()))
+		case discordgo.ApplicationCommandOptionInteger:
+			args = append(args, strconv.FormatInt(o.IntValue(), 10))
+		case discordgo.ApplicationCommandOptionBoolean:
+			if o.BoolValue() {
+				args = append(args, o.Name)
+			}
+		case discordgo.ApplicationCommandOptionAttachment:
+			id, _ := o.Value.(string)
+			if data.Resolved != nil && data.Resolved.Attachments[id] != nil {
+				attachments = append(attachments, data.Resolved.Attachments[id])
+			}
+		}
+	}
+	return strings.Join(args, " "), attachments
+}
+
+// ApplicationCommandHandler executes a slash command like the corresponding ? or # command.
+// The command is echoed as the response to the interaction, the handlers reply in the channel.
+func (b *Bot) ApplicationCommandHandler(s DiscordSession, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	author := interactionAuthor(i)
+	isAdmin := b.DiscordAdmin != "" && author == b.DiscordAdmin
+
+	cmd, ok := b.slashCommand(data.Name)
+	if !ok {
+		respondEphemeral(s, i, "invalid command: "+data.Name)
+		return
+	}
+
+	if !isAdmin && (cmd.Admin || !b.DiscordModerators.Contains(author)) {
+		respondEphemeral(s, i, "you are not allowed to access this command.")
+		return
+	}
+
+	_, bound := b.GetAddressByChannelID(i.ChannelID)
+	if !bound && !(isAdmin && (data.Name == "moderate" || data.Name == "rebind" || data.Name == "unmoderate")) {
+		respondEphemeral(s, i, "this channel is not bound to any server.")
+		return
+	}
+
+	args, attachments := slashCommandArgs(cmd, data)
+
+	prefix := "?"
+	if isAdmin {
+		prefix = "#"
+	}
+	line := strings.TrimSpace(prefix + data.Name + " " + args)
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: WrapInInlineCodeBlock(line),
+		},
+	})
+	if err != nil {
+		log.Printf("error while responding to /%s: %s", data.Name, err.Error())
+		return
+	}
+
+	c := &CommandContext{
+		Session: s,
+		Bot:     b,
+		Message: &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:          i.ID,
+				ChannelID:   i.ChannelID,
+				GuildID:     i.GuildID,
+				Author:      interactionUser(i),
+				Content:     line,
+				Attachments: attachments,
+			},
+		},
+		Author:  author,
+		Command: data.Name,
+		Args:    args,
+	}
+
+	if isAdmin {
+		AdminCommandsHandler(c)
+	} else {
+		ModeratorCommandsHandler(c)
+	}
+}
+
+// AutocompleteHandler suggests online players, known nicknames and server addresses while a moderator types a slash command.
+func (b *Bot) AutocompleteHandler(s DiscordSession, i *discordgo.InteractionCreate) {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxAutocompleteChoices)
+
+	if b.DiscordModerators.Contains(interactionAuthor(i)) {
+		for _, o := range i.ApplicationCommandData().Options {
+			if !o.Focused {
+				continue
+			}
+
+			value, _ := o.Value.(string)
+			switch o.Name {
+			case playerOption:
+				choices = b.playerChoices(i.ChannelID, value)
+			case nicknameOption:
+				choices = b.nicknameChoices(i.ChannelID, value)
+			case addressOption:
+				choices = b.addressChoices(value)
+			}
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("error while suggesting autocomplete choices: %s", err.Error())
+	}
+}
+
+// playerChoices suggests the online players of the channel's server whose ID or nickname matches the typed value, e.g. "12: nickname".
+func (b *Bot) playerChoices(channelID, value string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxAutocompleteChoices)
+
+	server, ok := b.GetServerByChannelID(channelID)
+	if !ok {
+		return choices
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, p := range server.Status() {
+		id := strconv.Itoa(p.ID)
+		if !strings.HasPrefix(id, value) && !strings.Contains(strings.ToLower(p.Name), value) {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s: %s", id, p.Name),
+			Value: id,
+		})
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+	}
+	return choices
+}
+
+// nicknameChoices suggests the nicknames of the online players and the known nicknames of the nickname tracking.
+func (b *Bot) nicknameChoices(channelID, value string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxAutocompleteChoices)
+	seen := make(map[string]bool, maxAutocompleteChoices)
+
+	add := func(nickname string) {
+		if nickname == "" || seen[nickname] || len(choices) == maxAutocompleteChoices {
+			return
+		}
+		seen[nickname] = true
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  nickname,
+			Value: nickname,
+		})
+	}
+
+	if server, ok := b.GetServerByChannelID(channelID); ok {
+		lower := strings.ToLower(value)
+		for _, p := range server.Status() {
+			if strings.Contains(strings.ToLower(p.Name), lower) {
+				add(p.Name)
+			}
+		}
+	}
+
+	nicknames, err := b.NicknameTracker.Search(value, maxAutocompleteChoices)
+	if err != nil {
+		log.Printf("error while searching nicknames: %s", err.Error())
+	}
+	for _, nickname := range nicknames {
+		add(nickname)
+	}
+	return choices
+}
+
+// addressChoices suggests the configured servers.
+func (b *Bot) addressChoices(value string) []*discordgo.ApplicationCommandOptionChoice {
+	addresses := make([]string, 0, len(b.EconPasswords))
+	for addr := range b.EconPasswords {
+		if strings.Contains(string(addr), value) {
+			addresses = append(addresses, string(addr))
+		}
+	}
+	sort.Strings(addresses)
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(addresses))
+	for _, addr := range addresses {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  addr,
+			Value: addr,
+		})
+	}
+	return choices
+}
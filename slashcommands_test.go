@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jxsl13/TeeworldsEconDiscordModerationBot/discordtest"
+)
+
+// newSlashCommandSession creates a fake session that passes all interactions to the bot.
+func newSlashCommandSession(b *Bot) *discordtest.Session {
+	session := discordtest.NewSession()
+	session.AddInteractionHandler(func(i *discordgo.InteractionCreate) {
+		b.InteractionCreateHandler(session, i)
+	})
+	return session
+}
+
+func stringValue(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: value,
+	}
+}
+
+func TestRegisterApplicationCommands(t *testing.T) {
+	b := newTestBot()
+	b.DiscordModeratorCommands.Add("kick")
+	b.DiscordModeratorCommands.Add("Invalid")
+
+	session := newSlashCommandSession(b)
+	if err := b.RegisterApplicationCommands(session, "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(map[string]*discordgo.ApplicationCommand)
+	for _, cmd := range session.ApplicationCommands() {
+		registered[cmd.Name] = cmd
+
+		if !applicationCommandNameRegex.MatchString(cmd.Name) {
+			t.Errorf("invalid command name: %s", cmd.Name)
+		}
+		if cmd.Description == "" || len(cmd.Description) > 100 {
+			t.Errorf("%s: description must contain 1 to 100 characters", cmd.Name)
+		}
+
+		optional := false
+		for _, o := range cmd.Options {
+			if o.Description == "" || len(o.Description) > 100 {
+				t.Errorf("%s %s: description must contain 1 to 100 characters", cmd.Name, o.Name)
+			}
+			if o.Required && optional {
+				t.Errorf("%s: required options must precede the optional options", cmd.Name)
+			}
+			optional = optional || !o.Required
+		}
+	}
+
+	for _, name := range []string{"multiban", "moderate", "kick"} {
+		if _, ok := registered[name]; !ok {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+	if _, ok := registered["Invalid"]; ok {
+		t.Errorf("invalid command names must not be registered")
+	}
+}
+
+func TestApplicationCommandHandler(t *testing.T) {
+	b := newTestBot()
+	b.BanEscalation = defaultBanEscalation
+	b.ServerStates[testAddress].UpdateStatus(Player{
+		ID:      5,
+		Name:    "griefer",
+		IP:      "192.168.178.26",
+		Port:    64140,
+		Country: -1,
+	})
+	session := newSlashCommandSession(b)
+
+	resp, err := session.ExecuteCommand(testChannelID, "punish", testUser, stringValue(playerOption, "5"), stringValue("reason", "spam"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("non-moderators must be answered privately: %#v", resp.Data)
+	}
+
+	resp, err = session.ExecuteCommand(testChannelID, "add", testModerator, stringValue("user", "user#0003"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Flags&discordgo.MessageFlagsEphemeral == 0 || b.DiscordModerators.Contains(testUser.String()) {
+		t.Errorf("moderators must not execute admin commands")
+	}
+
+	resp, err = session.ExecuteCommand(testChannelID, "punish", testModerator, stringValue(playerOption, "5"), stringValue("reason", "spam"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Content != "`?punish 5 spam`" {
+		t.Errorf("unexpected response: %q", resp.Data.Content)
+	}
+
+	select {
+	case cmd := <-b.DiscordCommandQueue[testAddress]:
+		if cmd.Command != "ban 5 5 spam" || cmd.Author != testModerator.String() {
+			t.Errorf("unexpected command: %#v", cmd)
+		}
+	default:
+		t.Errorf("player was not punished")
+	}
+}
+
+func TestAutocompleteHandler(t *testing.T) {
+	b := newTestBot()
+	b.ServerStates[testAddress].UpdateStatus(Player{ID: 5, Name: "griefer", IP: "192.168.178.26", Port: 64140, Country: -1})
+	b.ServerStates[testAddress].UpdateStatus(Player{ID: 12, Name: "nameless tee", IP: "192.168.178.27", Port: 64141, Country: -1})
+	session := newSlashCommandSession(b)
+
+	focused := stringValue(playerOption, "grief")
+	focused.Focused = true
+
+	resp, err := session.Autocomplete(testChannelID, "punish", testModerator, focused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Choices) != 1 || resp.Data.Choices[0].Name != "5: griefer" || resp.Data.Choices[0].Value != "5" {
+		t.Errorf("unexpected choices: %#v", resp.Data.Choices)
+	}
+
+	resp, err = session.Autocomplete(testChannelID, "punish", testUser, focused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Choices) != 0 {
+		t.Errorf("non-moderators must not see the online players")
+	}
+}
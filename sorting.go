@@ -14,6 +14,13 @@ func (a byAddress) Less(i, j int) bool { return a[i] < a[j] }
 
 type byBantime []Ban
 
-func (a byBantime) Len() int           { return len(a) }
-func (a byBantime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byBantime) Less(i, j int) bool { return a[i].ExpiresAt.Before(a[j].ExpiresAt) }
+func (a byBantime) Len() int      { return len(a) }
+func (a byBantime) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// permanent bans are sorted to the end, just like the Teeworlds server does.
+func (a byBantime) Less(i, j int) bool {
+	if a[i].Permanent() || a[j].Permanent() {
+		return !a[i].Permanent() && a[j].Permanent()
+	}
+	return a[i].ExpiresAt.Before(a[j].ExpiresAt)
+}
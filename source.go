@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// addresses with this prefix are the paths of server log files instead of econ addresses
+	logFilePrefix = "file:"
+
+	// how often a log file is checked for new lines, rotation and truncation
+	logFilePollInterval = 500 * time.Millisecond
+)
+
+var (
+	// ErrCommandsDisabled is returned when a command is executed on a log file without an econ endpoint.
+	ErrCommandsDisabled = errors.New("commands are disabled for this server, it is moderated via its log file")
+)
+
+// LogFile returns the path of the log file, if the address is configured as file:/path/to/server.log
+func (a Address) LogFile() (string, bool) {
+	if !strings.HasPrefix(string(a), logFilePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(string(a), logFilePrefix), true
+}
+
+// econAddress returns the address of the server's external console,
+// false is returned if the server is moderated via its log file without an econ endpoint.
+func (b *Bot) econAddress(addr Address) (Address, bool) {
+	if _, isFile := addr.LogFile(); !isFile {
+		return addr, true
+	}
+
+	econAddr, ok := b.CommandAddresses[addr]
+	return econAddr, ok
+}
+
+// openSource starts reading the log lines of the server, which are passed to result.
+// The passed connection executes the commands and is nil if commands are disabled,
+// it is connected as soon as openSource returns.
+// The source is closed when the context is cancelled, a closed result channel means
+// that the source failed permanently.
+func (b *Bot) openSource(ctx context.Context, s DiscordSession, addr Address, pass password, conn *EconConn, result chan<- string) bool {
+	path, isFile := addr.LogFile()
+	if !isFile {
+		if !b.openEcon(ctx, s, conn, addr, addr, pass) {
+			return false
+		}
+
+		go b.econReaderRoutine(ctx, s, conn, addr, addr, pass, result)
+		return true
+	}
+
+	tail, err := OpenFileTail(path)
+	if err != nil {
+		b.sendToServerChannel(s, addr, fmt.Sprintf("**[log]**: could not open %s: %s", path, err.Error()))
+		return false
+	}
+	go b.fileReaderRoutine(ctx, s, tail, addr, result)
+
+	if conn == nil {
+		return true
+	}
+
+	econAddr, _ := b.econAddress(addr)
+	if !b.openEcon(ctx, s, conn, addr, econAddr, pass) {
+		return false
+	}
+
+	// the responses are logged to the file as well, the econ lines are not needed
+	go b.econReaderRoutine(ctx, s, conn, addr, econAddr, pass, nil)
+	return true
+}
+
+// openEcon connects the passed connection to the external console at econAddr, which is the address
+// of the server unless the server is moderated via its log file.
+// The connection is closed when the context is cancelled.
+func (b *Bot) openEcon(ctx context.Context, s DiscordSession, conn *EconConn, addr, econAddr Address, pass password) bool {
+	link, err := dialEconLink(econAddr, pass)
+	if errors.Is(err, ErrInvalidPassword) {
+		b.sendToServerChannel(s, addr, err.Error())
+		return false
+	} else if err != nil {
+		b.sendToServerChannel(s, addr, fmt.Sprintf("**[econ]**: could not connect to %s, retrying...", econAddr))
+
+		link, err = dialEcon(ctx, econAddr, pass)
+		if err != nil {
+			return false
+		}
+	}
+
+	conn.Reset(link)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return true
+}
+
+// fileReaderRoutine reads the lines that are appended to the log file of a server.
+func (b *Bot) fileReaderRoutine(routineContext context.Context, s DiscordSession, tail *FileTail, addr Address, result chan<- string) {
+	defer log.Println("Closing log file reader routine of:", addr)
+	defer tail.Close()
+
+	for {
+		line, err := tail.ReadLine(routineContext)
+		if err != nil {
+			if routineContext.Err() != nil {
+				return
+			}
+
+			log.Printf("error while reading the log file of %s: %s\n", addr, err.Error())
+			b.sendToServerChannel(s, addr, fmt.Sprintf("**[log]**: could not read %s: %s", tail.path, err.Error()))
+			close(result)
+			return
+		}
+
+		select {
+		case <-routineContext.Done():
+			return
+		case result <- line:
+		}
+	}
+}
+
+// FileTail follows a log file like tail -F, rotated and truncated files are reopened.
+// It must only be used by a single goroutine.
+type FileTail struct {
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+}
+
+// OpenFileTail opens the log file, only lines that are appended afterwards are read.
+func OpenFileTail(path string) (*FileTail, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &FileTail{
+		path:   path,
+		file:   file,
+		reader: bufio.NewReader(file),
+		offset: offset,
+	}, nil
+}
+
+// ReadLine blocks until a complete line was appended to the file or the context is cancelled.
+func (t *FileTail) ReadLine(ctx context.Context) (string, error) {
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.offset += int64(len(chunk))
+		t.partial += chunk
+
+		if err == nil {
+			line := strings.TrimRight(t.partial, "\r\n")
+			t.partial = ""
+			return line, nil
+		} else if err != io.EOF {
+			return "", err
+		}
+
+		timer := time.NewTimer(logFilePollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+
+		err = t.reopenIfRotated()
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// reopenIfRotated opens the file at the path again, if it was replaced or truncated.
+func (t *FileTail) reopenIfRotated() error {
+	info, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		// rotation in progress, wait for the new file
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	current, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	rotated := !os.SameFile(info, current)
+	truncated := !rotated && info.Size() < t.offset
+	if !rotated && !truncated {
+		return nil
+	}
+
+	// read the lines that were written to the old file before the rotation first
+	if rotated {
+		if _, err := t.reader.Peek(1); err == nil {
+			return nil
+		}
+	}
+
+	file, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	t.file.Close()
+	t.file = file
+	t.reader = bufio.NewReader(file)
+	t.offset = 0
+	t.partial = ""
+	return nil
+}
+
+// Close closes the log file.
+func (t *FileTail) Close() {
+	t.file.Close()
+}
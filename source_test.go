@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendToFile(t *testing.T, path, text string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filetail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.log")
+	appendToFile(t, path, "old line\n")
+
+	tail, err := OpenFileTail(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tail.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	expectLine := func(expected string) {
+		t.Helper()
+
+		line, err := tail.ReadLine(ctx)
+		if err != nil {
+			t.Fatalf("expected %q, got error: %s", expected, err)
+		}
+		if line != expected {
+			t.Fatalf("expected %q, got %q", expected, line)
+		}
+	}
+
+	// incomplete lines are not returned
+	appendToFile(t, path, "first ")
+	appendToFile(t, path, "line\r\n")
+	expectLine("first line")
+
+	// rotation: the remaining lines of the old file are read first
+	appendToFile(t, path, "before rotation\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, path, "after rotation\n")
+	expectLine("before rotation")
+	expectLine("after rotation")
+
+	// truncation is detected as soon as the file is smaller than the read position
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, path, "truncated\n")
+	expectLine("truncated")
+
+	cancel()
+	if _, err := tail.ReadLine(ctx); err == nil {
+		t.Fatal("expected an error after cancelling the context")
+	}
+}
+
+func TestAddress_LogFile(t *testing.T) {
+	if path, ok := Address("file:/var/log/teeworlds/server.log").LogFile(); !ok || path != "/var/log/teeworlds/server.log" {
+		t.Errorf("unexpected log file: %q", path)
+	}
+
+	if _, ok := Address("127.0.0.1:9303").LogFile(); ok {
+		t.Error("econ address should not be a log file")
+	}
+}
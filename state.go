@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// botState is the part of the bot's state that survives restarts.
+type botState struct {
+	Bindings []ChannelBinding `json:"bindings"`
+}
+
+// LoadBindings reads the channel bindings from the state file.
+// A missing state file is not an error.
+func LoadBindings(path string) ([]ChannelBinding, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var state botState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, err
+	}
+	return state.Bindings, nil
+}
+
+// SaveBindings writes the channel bindings to the state file.
+func SaveBindings(path string, bindings []ChannelBinding) error {
+	data, err := json.MarshalIndent(botState{Bindings: bindings}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces the file atomically in order not to corrupt it when the bot is killed while writing.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveBindings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	bindings, err := LoadBindings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bindings) != 0 {
+		t.Fatal("bindings of a missing state file should be empty")
+	}
+
+	expected := []ChannelBinding{
+		{ChannelID: "1", GuildID: "10", Address: "127.0.0.1:9303", MessageID: "100"},
+		{ChannelID: "2", GuildID: "10", Address: "127.0.0.1:9304", MessageID: "200"},
+	}
+
+	err = SaveBindings(path, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindings, err = LoadBindings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(bindings, expected) {
+		t.Fatalf("Expected %v, got %v", expected, bindings)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// votes last 25 seconds, unless the server reports a different sv_vote_time
+	defaultVoteTime = 25 * time.Second
+
+	// echoed by the bot after requesting the vote time
+	voteTimeSyncMarker = "[Discord] synchronized vote time"
+)
+
+// sv_vote_time 20
+var voteTimeCommandRegex = regexp.MustCompile(`^\s*sv_vote_time\s+"?(\d+)"?\s*$`)
+
+// VoteOutcome tells how a vote ended, running votes have no outcome yet.
+type VoteOutcome string
+
+// possible vote outcomes
+const (
+	VoteRunning  VoteOutcome = ""
+	VotePassed   VoteOutcome = "passed"
+	VoteFailed   VoteOutcome = "failed"
+	VoteAborted  VoteOutcome = "aborted"
+	VoteTimedOut VoteOutcome = "timed out"
+)
+
+// Vote is the lifecycle of a vote on a server.
+type Vote struct {
+	ID        int
+	Start     VoteStartEvent
+	StartedAt time.Time
+
+	// "yes" or "no" if the vote was forced, the author is the Discord user that forced it
+	Forced   string
+	ForcedBy string
+
+	Outcome VoteOutcome
+}
+
+// Render formats the outcome of the vote, e.g. **[vote passed]**: forced yes by moderator#1234
+func (v Vote) Render() string {
+	outcome := v.Outcome
+	if outcome == VoteRunning {
+		outcome = "running"
+	}
+
+	line := fmt.Sprintf("**[vote %s]**", outcome)
+	if v.Forced == "" {
+		return line
+	}
+
+	line += ": forced " + v.Forced
+	if v.ForcedBy != "" {
+		line += " by " + Escape(v.ForcedBy)
+	}
+	return line
+}
+
+// VoteTracker keeps track of the current vote of a server.
+// Servers only have a single vote at a time, starting a vote ends the previous one.
+type VoteTracker struct {
+	mu       sync.Mutex
+	nextID   int
+	current  Vote
+	previous Vote
+	watchers []context.CancelFunc
+
+	// sv_vote_time of the server, zero until it is known
+	time        time.Duration
+	timeSyncing bool
+}
+
+// Time returns how long the votes of the server last.
+func (t *VoteTracker) Time() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.time <= 0 {
+		return defaultVoteTime
+	}
+	return t.time
+}
+
+// SetTime updates the vote time after the server's sv_vote_time changed.
+func (t *VoteTracker) SetTime(seconds int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.time = time.Duration(seconds) * time.Second
+}
+
+// BeginTimeSync marks the vote time as requested, the server responds with the value of sv_vote_time.
+func (t *VoteTracker) BeginTimeSync() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timeSyncing = true
+}
+
+// SyncTime sets the vote time to a value that was printed by the server.
+// The value is ignored if the bot did not request the vote time, false is returned in that case.
+func (t *VoteTracker) SyncTime(seconds int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.timeSyncing {
+		return false
+	}
+	t.time = time.Duration(seconds) * time.Second
+	return true
+}
+
+// EndTimeSync stops waiting for the vote time, servers without sv_vote_time keep the default vote time.
+func (t *VoteTracker) EndTimeSync() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timeSyncing = false
+}
+
+// TimeCommand updates the vote time if the command sets sv_vote_time.
+func (t *VoteTracker) TimeCommand(cmd string) {
+	matches := voteTimeCommandRegex.FindStringSubmatch(cmd)
+	if matches == nil {
+		return
+	}
+
+	seconds, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return
+	}
+	t.SetTime(seconds)
+}
+
+// Start begins tracking a new vote and returns its ID.
+// A vote that is still running is considered timed out, as the server did not report its outcome.
+func (t *VoteTracker) Start(e VoteStartEvent) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.ID != 0 && t.current.Outcome == VoteRunning {
+		t.end(VoteTimedOut)
+	}
+
+	t.nextID++
+	t.current = Vote{
+		ID:        t.nextID,
+		Start:     e,
+		StartedAt: time.Now(),
+	}
+	return t.current.ID
+}
+
+// Force records that the current vote was forced, the author is empty if it was not forced from Discord.
+func (t *VoteTracker) Force(yes bool, author string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.ID == 0 || t.current.Outcome != VoteRunning {
+		return
+	}
+
+	t.current.Forced = "no"
+	if yes {
+		t.current.Forced = "yes"
+	}
+	t.current.ForcedBy = author
+}
+
+// End ends the current vote with the outcome, false is returned if no vote is running.
+func (t *VoteTracker) End(outcome VoteOutcome) (Vote, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.ID == 0 || t.current.Outcome != VoteRunning {
+		return Vote{}, false
+	}
+	return t.end(outcome), true
+}
+
+// Expire ends the vote with the ID as timed out, if the server did not report its outcome in time.
+func (t *VoteTracker) Expire(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.ID == id && t.current.Outcome == VoteRunning {
+		t.end(VoteTimedOut)
+	}
+}
+
+// Get returns the current or the previous vote with the ID.
+func (t *VoteTracker) Get(id int) (Vote, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch id {
+	case 0:
+		return Vote{}, false
+	case t.current.ID:
+		return t.current, true
+	case t.previous.ID:
+		return t.previous, true
+	}
+	return Vote{}, false
+}
+
+// WatchContext returns a context that is cancelled as soon as the vote with the ID ended.
+func (t *VoteTracker) WatchContext(parent context.Context, id int) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.ID != id || t.current.Outcome != VoteRunning {
+		cancel()
+		return ctx, cancel
+	}
+	t.watchers = append(t.watchers, cancel)
+	return ctx, cancel
+}
+
+// end must be called while holding the lock.
+func (t *VoteTracker) end(outcome VoteOutcome) Vote {
+	t.current.Outcome = outcome
+	t.previous = t.current
+
+	for _, cancel := range t.watchers {
+		cancel()
+	}
+	t.watchers = nil
+	return t.current
+}
+
+// VoteStarted starts tracking the vote of the event, the event is returned with the vote's ID.
+func (s *Server) VoteStarted(e VoteStartEvent) VoteStartEvent {
+	e.ID = s.Votes.Start(e)
+	return e
+}
+
+// VoteForced records that the current vote was forced.
+// Forced votes are associated with the Discord user that executed the vote command shortly before.
+func (s *Server) VoteForced(yes bool) VoteForcedEvent {
+	s.RLock()
+	author := ""
+	if time.Since(s.commandAuthorAt) <= commandAuthorTimeout {
+		author = s.commandAuthor
+	}
+	s.RUnlock()
+
+	s.Votes.Force(yes, author)
+	return VoteForcedEvent{Yes: yes, Author: author}
+}
+
+// VoteEnded ends the current vote, nil is returned if no vote is running.
+func (s *Server) VoteEnded(outcome VoteOutcome) Event {
+	vote, ok := s.Votes.End(outcome)
+	if !ok {
+		return nil
+	}
+	return VoteEndEvent{Vote: vote}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVoteTracker(t *testing.T) {
+	var votes VoteTracker
+
+	if _, ok := votes.End(VotePassed); ok {
+		t.Errorf("no vote is running")
+	}
+
+	first := votes.Start(VoteStartEvent{Type: VoteKick})
+	ctx, cancel := votes.WatchContext(context.Background(), first)
+	defer cancel()
+
+	votes.Force(false, "moderator#0001")
+	vote, ok := votes.End(VoteFailed)
+	if !ok || vote.ID != first || vote.Outcome != VoteFailed || vote.ForcedBy != "moderator#0001" {
+		t.Errorf("unexpected vote: %#v", vote)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("watchers must be cancelled as soon as the vote ended")
+	}
+	if vote.Render() != "**[vote failed]**: forced no by "+Escape("moderator#0001") {
+		t.Errorf("unexpected outcome: %s", vote.Render())
+	}
+
+	// votes without a reported outcome
+	second := votes.Start(VoteStartEvent{Type: VoteSpectate})
+	third := votes.Start(VoteStartEvent{Type: VoteOption})
+	if vote, ok := votes.Get(second); !ok || vote.Outcome != VoteTimedOut {
+		t.Errorf("starting a vote must end the previous one: %#v", vote)
+	}
+
+	votes.Expire(third)
+	if vote, ok := votes.Get(third); !ok || vote.Outcome != VoteTimedOut {
+		t.Errorf("vote must be timed out: %#v", vote)
+	}
+	if _, ok := votes.Get(first); ok {
+		t.Errorf("only the current and the previous vote are kept")
+	}
+
+	ctx, cancel = votes.WatchContext(context.Background(), third)
+	defer cancel()
+	if ctx.Err() == nil {
+		t.Errorf("watching an ended vote must return a cancelled context")
+	}
+}
+
+func TestVoteTracker_Time(t *testing.T) {
+	var votes VoteTracker
+
+	if votes.Time() != defaultVoteTime {
+		t.Errorf("expected the default vote time, got %s", votes.Time())
+	}
+
+	if votes.SyncTime(20) {
+		t.Errorf("values that were not requested must be ignored")
+	}
+
+	votes.BeginTimeSync()
+	if !votes.SyncTime(20) || votes.Time() != 20*time.Second {
+		t.Errorf("expected the requested vote time, got %s", votes.Time())
+	}
+	votes.EndTimeSync()
+
+	votes.TimeCommand("sv_vote_time 15")
+	if votes.Time() != 15*time.Second {
+		t.Errorf("expected the vote time of the command, got %s", votes.Time())
+	}
+
+	votes.TimeCommand("sv_vote_time")
+	votes.TimeCommand("sv_vote_time_kick 40")
+	if votes.Time() != 15*time.Second {
+		t.Errorf("only commands that set sv_vote_time change the vote time, got %s", votes.Time())
+	}
+}